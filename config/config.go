@@ -1,13 +1,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// DefaultJWTSecret is the development fallback; production refuses to start with it.
+const DefaultJWTSecret = "eman-super-secret-jwt-key-change-in-production"
+
 type Config struct {
+	Env       string // development, production
 	Port      string
 	Domain    string
 	AppSecret string
@@ -16,14 +22,68 @@ type Config struct {
 	TelegramBotToken        string
 	TelegramChatID          string
 
+	// Reverse proxy: the client IP is read from ProxyHeader, but only on
+	// requests coming from TrustedProxies (IPs or CIDR ranges)
+	ProxyHeader    string
+	TrustedProxies []string
+
+	// Currency conversion
+	BaseCurrency              string
+	ExchangeRatesURL          string
+	ExchangeRatesSyncInterval time.Duration
+
+	// Reservations
+	ReservationDefaultHours int
+	ReservationMaxHours     int
+
 	// Database
 	DBDSN string
 
+	// Live updates across instances: local (single instance) or postgres
+	Backplane string
+
+	// Frontend on-demand revalidation hook, called after content edits
+	RevalidateURL    string
+	RevalidateSecret string
+
 	// JWT Auth
 	JWTSecret     string
 	JWTExpiry     int // minutes
 	RefreshExpiry int // minutes
 
+	// JWT key rotation. With a signing key file tokens are signed with that
+	// EdDSA/RS256 key; otherwise with JWTSecret (HS256). Previous secrets and
+	// key files only verify tokens issued before they were retired, and only
+	// for JWTKeyGracePeriod after that. Each entry may carry its retirement
+	// time as "value@2024-05-01T10:00:00Z"; JWTKeysRetiredAt covers the rest.
+	JWTSigningKeyFile   string
+	JWTPreviousKeyFiles []string
+	JWTPreviousSecrets  []string
+	JWTKeysRetiredAt    string // RFC 3339
+	JWTKeyGracePeriod   time.Duration
+
+	// Login brute-force protection
+	LoginMaxFailures   int // per username before lockout
+	LoginIPMaxFailures int // per IP before lockout
+	LoginLockout       time.Duration
+
+	// Two-factor authentication
+	TOTPIssuer string // shown in authenticator apps
+
+	// Audit log
+	AuditLogRetention time.Duration // 0 keeps entries forever
+
+	// Password reset
+	PasswordResetTTL time.Duration
+	PasswordResetURL string // admin page that takes ?token=; the raw token is sent when empty
+
+	// Outgoing email (password reset delivery)
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
 	// Admin credentials (used for initial seed)
 	AdminUsername string
 	AdminPassword string
@@ -32,10 +92,41 @@ type Config struct {
 	UploadDir       string
 	MaxUploadSizeMB int
 
+	// Resumable (tus) uploads for files larger than one request
+	ResumableMaxSizeMB int
+	ResumableUploadTTL time.Duration // abandoned uploads are removed after this
+
+	// Upload storage: local (UploadDir) or s3
+	StorageBackend   string
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3UseSSL         bool
+	S3ForcePathStyle bool   // required by MinIO
+	S3Prefix         string // key prefix inside the bucket
+	S3PublicURL      string // CDN or bucket URL files are served from
+
+	// Unreferenced uploads are deleted after this grace period
+	MediaGCGrace time.Duration
+
+	// Malware scanning of uploads: off or clamav
+	MalwareScanner string
+	ClamAVAddress  string        // host:port, or unix:/path/to/clamd.sock
+	ClamAVTimeout  time.Duration // per read/write on the clamd connection
+	ScanMaxSizeMB  int           // larger files are refused while scanning is on
+	ScanFailOpen   bool          // keep uploads when the scanner is unreachable
+
 	// Image processing
 	WebPQuality  int
 	WebPLossless bool
 	WebPExact    bool
+
+	// Responsive images
+	ImageVariantWidths []int    // widths generated at upload time
+	ImageResizeSizes   []string // "WxH" sizes /img may serve; 0 keeps the aspect ratio
+	ImageCacheDir      string   // on-demand resizes are cached here
 }
 
 func Load() *Config {
@@ -61,6 +152,7 @@ func Load() *Config {
 	}
 
 	return &Config{
+		Env:       strings.ToLower(getEnv("APP_ENV", "development")),
 		Port:      getEnv("PORT", "8080"),
 		Domain:    getEnv("MACRO_DOMAIN", "eman-riverside.vercel.app"),
 		AppSecret: getEnv("MACRO_APP_SECRET", "zUHxHqwGhPcvy39QD2r3huFCnK3UuKW26C9E"),
@@ -69,14 +161,60 @@ func Load() *Config {
 		TelegramBotToken:        getEnv("TELEGRAM_BOT_TOKEN", ""),
 		TelegramChatID:          getEnv("TELEGRAM_CHAT_ID", ""),
 
+		// Reverse proxy
+		ProxyHeader:    getEnv("PROXY_HEADER", "X-Real-IP"),
+		TrustedProxies: getEnvListDefault("TRUSTED_PROXIES", []string{"127.0.0.1", "::1"}),
+
+		// Currency conversion
+		BaseCurrency:              strings.ToUpper(getEnv("BASE_CURRENCY", "UZS")),
+		ExchangeRatesURL:          getEnv("EXCHANGE_RATES_URL", ""),
+		ExchangeRatesSyncInterval: getEnvDuration("EXCHANGE_RATES_SYNC_INTERVAL", 6*time.Hour),
+
+		// Reservations
+		ReservationDefaultHours: getEnvInt("RESERVATION_DEFAULT_HOURS", 24),
+		ReservationMaxHours:     getEnvInt("RESERVATION_MAX_HOURS", 48),
+
 		// Database
 		DBDSN: dbDSN,
 
+		Backplane: strings.ToLower(getEnv("HUB_BACKPLANE", "local")),
+
+		RevalidateURL:    getEnv("REVALIDATE_URL", ""),
+		RevalidateSecret: getEnv("REVALIDATE_SECRET", ""),
+
 		// JWT Auth
-		JWTSecret:     getEnv("JWT_SECRET", "eman-super-secret-jwt-key-change-in-production"),
+		JWTSecret:     getEnv("JWT_SECRET", DefaultJWTSecret),
 		JWTExpiry:     15,    // 15 minutes
 		RefreshExpiry: 10080, // 7 days
 
+		JWTSigningKeyFile:   getEnv("JWT_SIGNING_KEY_FILE", ""),
+		JWTPreviousKeyFiles: getEnvList("JWT_PREVIOUS_KEY_FILES"),
+		JWTPreviousSecrets:  getEnvList("JWT_PREVIOUS_SECRETS"),
+		JWTKeysRetiredAt:    getEnv("JWT_KEYS_RETIRED_AT", ""),
+		JWTKeyGracePeriod:   getEnvDuration("JWT_KEY_GRACE_PERIOD", 24*time.Hour),
+
+		// Login brute-force protection
+		LoginMaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures: getEnvInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginLockout:       getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+
+		// Two-factor authentication
+		TOTPIssuer: getEnv("TOTP_ISSUER", "EMAN Riverside"),
+
+		// Audit log
+		AuditLogRetention: getEnvDurationOrZero("AUDIT_LOG_RETENTION", 365*24*time.Hour),
+
+		// Password reset
+		PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		PasswordResetURL: getEnv("PASSWORD_RESET_URL", ""),
+
+		// Outgoing email
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
 		// Admin credentials
 		AdminUsername: getEnv("ADMIN_USERNAME", "admin"),
 		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
@@ -85,13 +223,70 @@ func Load() *Config {
 		UploadDir:       getEnv("UPLOAD_DIR", "./uploads"),
 		MaxUploadSizeMB: getEnvInt("MAX_UPLOAD_SIZE_MB", 200),
 
+		// Resumable uploads
+		ResumableMaxSizeMB: getEnvInt("RESUMABLE_MAX_SIZE_MB", 2048),
+		ResumableUploadTTL: getEnvDuration("RESUMABLE_UPLOAD_TTL", 24*time.Hour),
+
+		// Upload storage
+		StorageBackend:   strings.ToLower(getEnv("STORAGE_BACKEND", "local")),
+		S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+		S3Region:         getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:         getEnv("S3_BUCKET", ""),
+		S3AccessKey:      getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:      getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:         getEnvBool("S3_USE_SSL", true),
+		S3ForcePathStyle: getEnvBool("S3_FORCE_PATH_STYLE", false),
+		S3Prefix:         getEnv("S3_PREFIX", ""),
+		S3PublicURL:      getEnv("S3_PUBLIC_URL", ""),
+
+		MediaGCGrace: getEnvDuration("MEDIA_GC_GRACE", 7*24*time.Hour),
+
+		// Malware scanning
+		MalwareScanner: strings.ToLower(getEnv("MALWARE_SCANNER", "off")),
+		ClamAVAddress:  getEnv("CLAMAV_ADDRESS", "localhost:3310"),
+		ClamAVTimeout:  getEnvDuration("CLAMAV_TIMEOUT", 30*time.Second),
+		ScanMaxSizeMB:  getEnvInt("SCAN_MAX_SIZE_MB", 25),
+		ScanFailOpen:   getEnvBool("SCAN_FAIL_OPEN", false),
+
 		// Image processing
 		WebPQuality:  getEnvInt("WEBP_QUALITY", 85),
 		WebPLossless: getEnvBool("WEBP_LOSSLESS", false),
 		WebPExact:    getEnvBool("WEBP_EXACT", false),
+
+		// Responsive images
+		ImageVariantWidths: getEnvIntList("IMAGE_VARIANT_WIDTHS", []int{480, 960, 1600}),
+		ImageResizeSizes:   getEnvListDefault("IMAGE_RESIZE_SIZES", []string{"240x240", "480x0", "480x480", "960x0", "1600x0"}),
+		ImageCacheDir:      getEnv("IMAGE_CACHE_DIR", "./cache/images"),
 	}
 }
 
+// IsProduction reports whether APP_ENV is production.
+func (c *Config) IsProduction() bool {
+	return c.Env == "production"
+}
+
+// Validate rejects settings that are unsafe to run with.
+func (c *Config) Validate() error {
+	if c.IsProduction() && c.JWTSigningKeyFile == "" && c.JWTSecret == DefaultJWTSecret {
+		return errors.New("JWT_SECRET is the built-in default; set JWT_SECRET or JWT_SIGNING_KEY_FILE in production")
+	}
+	switch c.StorageBackend {
+	case "local":
+	case "s3":
+		if c.S3Endpoint == "" || c.S3Bucket == "" {
+			return errors.New("STORAGE_BACKEND=s3 needs S3_ENDPOINT and S3_BUCKET")
+		}
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q, expected local or s3", c.StorageBackend)
+	}
+	switch c.MalwareScanner {
+	case "off", "clamav":
+	default:
+		return fmt.Errorf("unknown MALWARE_SCANNER %q, expected off or clamav", c.MalwareScanner)
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -108,6 +303,38 @@ func getEnvFirst(keys ...string) string {
 	return ""
 }
 
+// getEnvList splits a comma-separated variable, dropping empty items.
+func getEnvList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func getEnvListDefault(key string, defaultValue []string) []string {
+	if list := getEnvList(key); len(list) > 0 {
+		return list
+	}
+	return defaultValue
+}
+
+func getEnvIntList(key string, defaultValue []int) []int {
+	list := getEnvList(key)
+	if len(list) == 0 {
+		return defaultValue
+	}
+	out := make([]int, 0, len(list))
+	for _, item := range list {
+		if parsed, err := strconv.Atoi(item); err == nil && parsed > 0 {
+			out = append(out, parsed)
+		}
+	}
+	return out
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil {
@@ -134,3 +361,14 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getEnvDurationOrZero is getEnvDuration for settings where 0 disables the
+// feature rather than falling back to the default.
+func getEnvDurationOrZero(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed == 0 {
+			return 0
+		}
+	}
+	return getEnvDuration(key, defaultValue)
+}
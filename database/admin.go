@@ -9,8 +9,15 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// EnsureAdminUser seeds the admin account if it does not exist.
+// EnsureAdminUser seeds the owner account if no admin exists yet.
 func EnsureAdminUser(defaultUsername, defaultPassword string) error {
+	// Accounts created before roles existed were the single all-powerful admin.
+	if err := DB.Model(&models.AdminUser{}).
+		Where("role IS NULL OR role = ''").
+		Update("role", models.RoleOwner).Error; err != nil {
+		return err
+	}
+
 	var count int64
 	if err := DB.Model(&models.AdminUser{}).Count(&count).Error; err != nil {
 		return err
@@ -38,6 +45,7 @@ func EnsureAdminUser(defaultUsername, defaultPassword string) error {
 	admin := models.AdminUser{
 		Username:          username,
 		PasswordHash:      string(hash),
+		Role:              models.RoleOwner,
 		PasswordChangedAt: &now,
 	}
 
@@ -2,6 +2,7 @@ package database
 
 import (
 	"eman-backend/models"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -155,6 +156,24 @@ func Migrate() error {
 		&models.SiteSetting{},
 		&models.Challenge{},
 		&models.ChallengeParticipant{},
+		&models.ExchangeRate{},
+		&models.Reservation{},
+		&models.Shortlist{},
+		&models.ShortlistItem{},
+		&models.AdminSession{},
+		&models.RefreshToken{},
+		&models.LoginAttempt{},
+		&models.LoginLockout{},
+		&models.AdminRecoveryCode{},
+		&models.SecurityPolicy{},
+		&models.AuditLog{},
+		&models.APIKey{},
+		&models.PasswordResetToken{},
+		&models.MediaAsset{},
+		&models.MediaReference{},
+		&models.UploadSession{},
+		&models.BackplaneChannel{},
+		&models.BackplaneMessage{},
 	)
 	if err != nil {
 		return err
@@ -205,6 +224,20 @@ func SeedSettings() error {
 				continue
 			}
 
+			// Plans saved before the calculator lack its numeric fields.
+			if setting.Key == "payment_plans" || setting.Key == "payment_plans_uz" || setting.Key == "payment_plans_en" {
+				if value, changed := backfillPaymentPlans(current.Value); changed {
+					if err := DB.Model(&models.SiteSetting{}).
+						Where("id = ?", current.ID).
+						Update("value", value).Error; err != nil {
+						log.Printf("Warning: Failed to update setting %s: %v", setting.Key, err)
+					} else {
+						updated++
+					}
+				}
+				continue
+			}
+
 			// Backfill empty FAQ JSON only on startup.
 			if setting.Type == models.TypeJSON && (setting.Key == "faq_items" || setting.Key == "faq_items_uz" || setting.Key == "faq_items_en") {
 				trimmed := strings.TrimSpace(current.Value)
@@ -221,7 +254,7 @@ func SeedSettings() error {
 		}
 
 		if added > 0 || updated > 0 {
-			log.Printf("Added %d missing default settings, updated %d empty FAQ settings", added, updated)
+			log.Printf("Added %d missing default settings, backfilled %d existing settings", added, updated)
 		} else {
 			log.Printf("Settings already exist (%d items), no defaults added", count)
 		}
@@ -237,3 +270,37 @@ func SeedSettings() error {
 	log.Printf("Seeded %d default settings", len(defaults))
 	return nil
 }
+
+// Calculator fields added to stored plans that predate them. A zero is what
+// the calculator assumes for an absent field anyway; term_months is left
+// out so that a plan stays unquotable until an admin sets its term.
+var paymentPlanDefaultFields = []string{"min_down_payment_percent", "interest_rate", "discount_percent"}
+
+// backfillPaymentPlans adds the calculator fields a stored plan lacks, so
+// the admin editor shows them. Values already present, including an
+// explicit 0, are left alone.
+func backfillPaymentPlans(stored string) (string, bool) {
+	var plans []map[string]any
+	if err := json.Unmarshal([]byte(stored), &plans); err != nil {
+		return "", false
+	}
+
+	changed := false
+	for _, plan := range plans {
+		for _, field := range paymentPlanDefaultFields {
+			if _, ok := plan[field]; !ok {
+				plan[field] = 0
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return "", false
+	}
+
+	value, err := json.Marshal(plans)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
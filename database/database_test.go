@@ -0,0 +1,36 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBackfillPaymentPlans(t *testing.T) {
+	stored := `[{"title":"Своя","features":["x"]},{"title":"Admin","min_down_payment_percent":15,"term_months":48,"interest_rate":0}]`
+
+	value, changed := backfillPaymentPlans(stored)
+	if !changed {
+		t.Fatal("plans missing calculator fields were not backfilled")
+	}
+	var plans []map[string]any
+	if err := json.Unmarshal([]byte(value), &plans); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing fields are added as zero, but never a term
+	if plans[0]["title"] != "Своя" || plans[0]["min_down_payment_percent"] != 0.0 || plans[0]["interest_rate"] != 0.0 {
+		t.Fatalf("first plan: %v", plans[0])
+	}
+	if _, ok := plans[0]["term_months"]; ok {
+		t.Fatalf("term invented for the first plan: %v", plans[0])
+	}
+
+	// Values an admin set, explicit zeros included, are kept
+	if plans[1]["min_down_payment_percent"] != 15.0 || plans[1]["term_months"] != 48.0 || plans[1]["interest_rate"] != 0.0 || plans[1]["discount_percent"] != 0.0 {
+		t.Fatalf("second plan: %v", plans[1])
+	}
+
+	if _, changed := backfillPaymentPlans(value); changed {
+		t.Fatal("backfill is not idempotent")
+	}
+}
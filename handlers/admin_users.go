@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AdminUsersHandler struct {
+	sessions *services.SessionService
+	resets   *services.PasswordResetService
+}
+
+func NewAdminUsersHandler(sessions *services.SessionService, resets *services.PasswordResetService) *AdminUsersHandler {
+	return &AdminUsersHandler{sessions: sessions, resets: resets}
+}
+
+// activeOwnerCount counts enabled owners, optionally excluding one account.
+func activeOwnerCount(excludeID uint) int64 {
+	var count int64
+	database.DB.Model(&models.AdminUser{}).
+		Where("role = ? AND disabled = ? AND id <> ?", models.RoleOwner, false, excludeID).
+		Count(&count)
+	return count
+}
+
+func findAdminUser(c *fiber.Ctx) (*models.AdminUser, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	var user models.AdminUser
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "User not found",
+		})
+	}
+	return &user, nil
+}
+
+// List returns all admin accounts (owner)
+func (h *AdminUsersHandler) List(c *fiber.Ctx) error {
+	var users []models.AdminUser
+	if err := database.DB.Order("created_at ASC").Find(&users).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch users",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items": users,
+		"total": len(users),
+	})
+}
+
+type CreateAdminUserRequest struct {
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	Role           string `json:"role"`
+	Email          string `json:"email"`
+	TelegramChatID string `json:"telegram_chat_id"`
+}
+
+// Create adds an admin account (owner)
+func (h *AdminUsersHandler) Create(c *fiber.Ctx) error {
+	var req CreateAdminUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Username and password are required",
+		})
+	}
+	if !models.ValidRole(req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid role",
+		})
+	}
+	if msg := passwordPolicyError(req.Password); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": msg,
+		})
+	}
+	contact, msg := contactUpdates(UpdateContactRequest{Email: &req.Email, TelegramChatID: &req.TelegramChatID})
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": msg,
+		})
+	}
+
+	var count int64
+	database.DB.Model(&models.AdminUser{}).Where("username = ?", req.Username).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Username already exists",
+		})
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create user",
+		})
+	}
+
+	now := time.Now()
+	user := models.AdminUser{
+		Username:          req.Username,
+		PasswordHash:      string(hash),
+		Role:              req.Role,
+		Email:             contact["email"].(string),
+		TelegramChatID:    contact["telegram_chat_id"].(string),
+		PasswordChangedAt: &now,
+	}
+
+	if err := database.DB.Create(&user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create user",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+type UpdateAdminUserRequest struct {
+	Role           string  `json:"role"`
+	Email          *string `json:"email"`
+	TelegramChatID *string `json:"telegram_chat_id"`
+}
+
+// Update changes an account's role and password reset contacts (owner)
+func (h *AdminUsersHandler) Update(c *fiber.Ctx) error {
+	user, err := findAdminUser(c)
+	if user == nil {
+		return err
+	}
+
+	var req UpdateAdminUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+	updates, msg := contactUpdates(UpdateContactRequest{Email: req.Email, TelegramChatID: req.TelegramChatID})
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": msg,
+		})
+	}
+
+	if req.Role != "" {
+		if !models.ValidRole(req.Role) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Invalid role",
+			})
+		}
+		if user.Role == models.RoleOwner && req.Role != models.RoleOwner && !user.Disabled && activeOwnerCount(user.ID) == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": "Cannot demote the last active owner",
+			})
+		}
+		updates["role"] = req.Role
+	}
+
+	contactChanged := false
+	if email, ok := updates["email"]; ok && email != user.Email {
+		contactChanged = true
+	}
+	if chatID, ok := updates["telegram_chat_id"]; ok && chatID != user.TelegramChatID {
+		contactChanged = true
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(user).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to update user",
+			})
+		}
+		// Links already sent went to the old address
+		if contactChanged {
+			if err := h.resets.RevokeOutstanding(user.ID); err != nil {
+				log.Printf("[Auth] failed to revoke reset tokens of %s: %v", user.Username, err)
+			}
+		}
+		database.DB.First(user, user.ID)
+	}
+
+	return c.JSON(user)
+}
+
+// Disable blocks an account from signing in (owner)
+func (h *AdminUsersHandler) Disable(c *fiber.Ctx) error {
+	user, err := findAdminUser(c)
+	if user == nil {
+		return err
+	}
+
+	if user.Username == currentUsername(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "You cannot disable your own account",
+		})
+	}
+	if user.Role == models.RoleOwner && activeOwnerCount(user.ID) == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Cannot disable the last active owner",
+		})
+	}
+
+	now := time.Now()
+	user.Disabled = true
+	user.DisabledAt = &now
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"disabled":    true,
+		"disabled_at": &now,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to disable user",
+		})
+	}
+	h.sessions.RevokeAll(user.ID, models.SessionRevokedAccountDisable)
+
+	return c.JSON(user)
+}
+
+// Enable restores a disabled account (owner)
+func (h *AdminUsersHandler) Enable(c *fiber.Ctx) error {
+	user, err := findAdminUser(c)
+	if user == nil {
+		return err
+	}
+
+	user.Disabled = false
+	user.DisabledAt = nil
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"disabled":    false,
+		"disabled_at": nil,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to enable user",
+		})
+	}
+
+	return c.JSON(user)
+}
+
+type ResetAdminPasswordRequest struct {
+	NewPassword string `json:"new_password"`
+}
+
+// ResetPassword sets a new password for another account (owner)
+func (h *AdminUsersHandler) ResetPassword(c *fiber.Ctx) error {
+	user, err := findAdminUser(c)
+	if user == nil {
+		return err
+	}
+
+	var req ResetAdminPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+	if msg := passwordPolicyError(req.NewPassword); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": msg,
+		})
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to reset password",
+		})
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password_hash":       string(hash),
+		"password_changed_at": &now,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to reset password",
+		})
+	}
+	h.sessions.RevokeAll(user.ID, models.SessionRevokedPasswordChange)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Password reset",
+	})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type APIKeysHandler struct {
+	apiKeys *services.APIKeyService
+}
+
+func NewAPIKeysHandler(apiKeys *services.APIKeyService) *APIKeysHandler {
+	return &APIKeysHandler{apiKeys: apiKeys}
+}
+
+func apiKeyResponse(key *models.APIKey) fiber.Map {
+	return fiber.Map{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       key.ScopeList(),
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"last_used_ip": key.LastUsedIP,
+		"created_by":   key.CreatedBy,
+		"rotated_at":   key.RotatedAt,
+		"revoked_at":   key.RevokedAt,
+		"active":       key.Active(),
+		"created_at":   key.CreatedAt,
+	}
+}
+
+// List returns all API keys without their secrets (owner)
+func (h *APIKeysHandler) List(c *fiber.Ctx) error {
+	var keys []models.APIKey
+	if err := database.DB.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch API keys",
+		})
+	}
+
+	items := make([]fiber.Map, 0, len(keys))
+	for i := range keys {
+		items = append(items, apiKeyResponse(&keys[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"items":  items,
+		"total":  len(items),
+		"scopes": models.APIKeyScopes(),
+	})
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string     `json:"name"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	ExpiresInDays int        `json:"expires_in_days"`
+}
+
+// Create issues a new key; the secret is returned only in this response (owner)
+func (h *APIKeysHandler) Create(c *fiber.Ctx) error {
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Name is required",
+		})
+	}
+
+	scopes, err := services.NormalizeScopes(req.Scopes)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Expiry must be in the future",
+		})
+	}
+
+	secret, key, err := h.apiKeys.Create(req.Name, scopes, expiresAt, currentUsername(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create API key",
+		})
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = secret
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// Rotate replaces a key's secret, keeping its name and scopes (owner)
+func (h *APIKeysHandler) Rotate(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	secret, key, err := h.apiKeys.Rotate(uint(id))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = secret
+	return c.JSON(response)
+}
+
+// Revoke disables a key permanently (owner)
+func (h *APIKeysHandler) Revoke(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	key, err := h.apiKeys.Revoke(uint(id))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	return c.JSON(apiKeyResponse(key))
+}
+
+func apiKeyError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "API key not found",
+		})
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "API key is revoked",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update API key",
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditLogsHandler struct {
+	audit *services.AuditService
+}
+
+func NewAuditLogsHandler(audit *services.AuditService) *AuditLogsHandler {
+	return &AuditLogsHandler{audit: audit}
+}
+
+// parseAuditTime accepts RFC 3339 timestamps or plain dates (YYYY-MM-DD).
+func parseAuditTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// List searches the audit log, newest first (owner)
+func (h *AuditLogsHandler) List(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Model(&models.AuditLog{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if method := c.Query("method"); method != "" {
+		query = query.Where("method = ?", strings.ToUpper(method))
+	}
+	if from, ok := parseAuditTime(c.Query("from")); ok {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, ok := parseAuditTime(c.Query("to")); ok {
+		if !strings.Contains(c.Query("to"), "T") {
+			to = to.Add(24 * time.Hour) // whole day
+		}
+		query = query.Where("created_at < ?", to)
+	}
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("route ILIKE ? OR changes ILIKE ?", like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.AuditLog
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch audit log",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items":          items,
+		"total":          total,
+		"page":           page,
+		"limit":          limit,
+		"retention_days": int(h.audit.Retention().Hours() / 24),
+	})
+}
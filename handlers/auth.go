@@ -4,6 +4,10 @@ import (
 	"eman-backend/config"
 	"eman-backend/database"
 	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,11 +17,16 @@ import (
 )
 
 type AuthHandler struct {
-	cfg *config.Config
+	cfg        *config.Config
+	keys       *services.JWTKeySet
+	sessions   *services.SessionService
+	loginGuard *services.LoginGuardService
+	twoFactor  *services.TwoFactorService
+	resets     *services.PasswordResetService
 }
 
-func NewAuthHandler(cfg *config.Config) *AuthHandler {
-	return &AuthHandler{cfg: cfg}
+func NewAuthHandler(cfg *config.Config, keys *services.JWTKeySet, sessions *services.SessionService, loginGuard *services.LoginGuardService, twoFactor *services.TwoFactorService, resets *services.PasswordResetService) *AuthHandler {
+	return &AuthHandler{cfg: cfg, keys: keys, sessions: sessions, loginGuard: loginGuard, twoFactor: twoFactor, resets: resets}
 }
 
 type LoginRequest struct {
@@ -32,13 +41,50 @@ type ChangePasswordRequest struct {
 }
 
 type LoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expires_at"`
+	Token         string   `json:"token"`
+	ExpiresAt     int64    `json:"expires_at"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
-type Claims struct {
-	Username string `json:"username"`
-	jwt.RegisteredClaims
+// issueAccessToken signs a short-lived access token bound to a session.
+func (h *AuthHandler) issueAccessToken(admin *models.AdminUser, sessionID uint) (string, time.Time, error) {
+	return h.signToken(admin, sessionID, "eman-backend", time.Duration(h.cfg.JWTExpiry)*time.Minute)
+}
+
+func (h *AuthHandler) signToken(admin *models.AdminUser, sessionID uint, issuer string, ttl time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
+	claims := services.Claims{
+		Username:  admin.Username,
+		Role:      admin.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+		},
+	}
+
+	tokenString, err := h.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+func setRefreshCookie(c *fiber.Ctx, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    value,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   false, // Set to true in production with HTTPS
+		SameSite: "Lax",
+		Path:     "/api/auth",
+	})
+}
+
+func clearRefreshCookie(c *fiber.Ctx) {
+	setRefreshCookie(c, "", time.Now().Add(-1*time.Hour))
 }
 
 // Login handles admin login
@@ -59,78 +105,120 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	ip := c.IP()
+	userAgent := c.Get("User-Agent")
+
+	if blocked, err := h.rejectThrottled(c, req.Username, ip, userAgent); blocked {
+		return err
+	}
+
 	// Validate credentials against database
 	var admin models.AdminUser
 	if err := database.DB.Where("username = ?", req.Username).First(&admin).Error; err != nil {
+		h.loginGuard.RecordFailure(req.Username, ip, userAgent, models.LoginReasonInvalidCredentials)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   true,
 			"message": "Invalid credentials",
 		})
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(req.Password)); err != nil {
+		h.loginGuard.RecordFailure(req.Username, ip, userAgent, models.LoginReasonInvalidCredentials)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   true,
 			"message": "Invalid credentials",
 		})
 	}
+	if admin.Disabled {
+		h.loginGuard.RecordAttempt(req.Username, ip, userAgent, false, models.LoginReasonDisabled)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   true,
+			"message": "Account is disabled",
+		})
+	}
 
-	// Generate JWT token
-	expiresAt := time.Now().Add(time.Duration(h.cfg.JWTExpiry) * time.Minute)
-	claims := Claims{
-		Username: admin.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "eman-backend",
-		},
+	// Password is right; enrolled admins (or everyone, under the owner policy)
+	// still have to pass the second factor before a session is created.
+	if admin.TOTPEnabled || h.twoFactor.Required() {
+		issuer := twoFactorVerifyIssuer
+		if !admin.TOTPEnabled {
+			issuer = twoFactorSetupIssuer
+		}
+		token, expiresAt, err := h.signToken(&admin, 0, issuer, twoFactorTokenTTL)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to generate token",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"two_factor_required":       admin.TOTPEnabled,
+			"two_factor_setup_required": !admin.TOTPEnabled,
+			"two_factor_token":          token,
+			"expires_at":                expiresAt.Unix(),
+		})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	h.loginGuard.RecordSuccess(req.Username, ip, userAgent)
+	return h.completeLogin(c, &admin, nil)
+}
+
+// rejectThrottled answers 429 while the username or IP is throttled or locked out.
+func (h *AuthHandler) rejectThrottled(c *fiber.Ctx, username, ip, userAgent string) (bool, error) {
+	wait, reason := h.loginGuard.Check(username, ip)
+	if reason == "" {
+		return false, nil
+	}
+
+	h.loginGuard.RecordAttempt(username, ip, userAgent, false, reason)
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	message := "Too many login attempts, try again in " + strconv.Itoa(seconds) + " seconds"
+	if reason == models.LoginReasonLocked {
+		message = "Too many failed login attempts, sign-in is locked for " + strconv.Itoa(seconds) + " seconds"
+	}
+	return true, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       true,
+		"message":     message,
+		"retry_after": seconds,
+	})
+}
+
+// completeLogin starts a server-side session, puts its refresh token into an
+// HTTP-only cookie and returns the access token.
+func (h *AuthHandler) completeLogin(c *fiber.Ctx, admin *models.AdminUser, recoveryCodes []string) error {
+	refreshToken, session, err := h.sessions.Start(admin.ID, c.Get("User-Agent"), c.IP())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
-			"message": "Failed to generate token",
+			"message": "Failed to generate refresh token",
 		})
 	}
 
-	// Generate refresh token
-	refreshExpiresAt := time.Now().Add(time.Duration(h.cfg.RefreshExpiry) * time.Minute)
-	refreshClaims := Claims{
-		Username: admin.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "eman-backend-refresh",
-		},
-	}
-
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(h.cfg.JWTSecret))
+	tokenString, expiresAt, err := h.issueAccessToken(admin, session.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
-			"message": "Failed to generate refresh token",
+			"message": "Failed to generate token",
 		})
 	}
 
-	// Set refresh token in HTTP-only cookie
-	c.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshTokenString,
-		Expires:  refreshExpiresAt,
-		HTTPOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: "Lax",
-		Path:     "/api/auth",
-	})
+	setRefreshCookie(c, refreshToken, session.ExpiresAt)
 
 	return c.JSON(LoginResponse{
-		Token:     tokenString,
-		ExpiresAt: expiresAt.Unix(),
+		Token:         tokenString,
+		ExpiresAt:     expiresAt.Unix(),
+		RecoveryCodes: recoveryCodes,
 	})
 }
 
+// passwordPolicyError returns a user-facing message when password is too weak.
+func passwordPolicyError(password string) string {
+	if len(password) < 8 {
+		return "New password must be at least 8 characters"
+	}
+	return ""
+}
+
 // ChangePassword updates admin password (protected)
 func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 	var req ChangePasswordRequest
@@ -147,10 +235,10 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 			"message": "Current and new password are required",
 		})
 	}
-	if len(req.NewPassword) < 8 {
+	if msg := passwordPolicyError(req.NewPassword); msg != "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   true,
-			"message": "New password must be at least 8 characters",
+			"message": msg,
 		})
 	}
 	if req.ConfirmPassword != "" && req.ConfirmPassword != req.NewPassword {
@@ -209,55 +297,67 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 		})
 	}
 
+	// Every session, including this one, has to sign in with the new password
+	if err := h.sessions.RevokeAll(admin.ID, models.SessionRevokedPasswordChange); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Password updated but sessions could not be revoked",
+		})
+	}
+	clearRefreshCookie(c)
+
 	return c.JSON(fiber.Map{
 		"success": true,
-		"message": "Password updated",
+		"message": "Password updated, please sign in again",
 	})
 }
 
-// Refresh generates new access token using refresh token
+// Refresh rotates the refresh token and issues a new access token
 func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
-	refreshTokenString := c.Cookies("refresh_token")
-	if refreshTokenString == "" {
+	refreshToken := c.Cookies("refresh_token")
+	if refreshToken == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   true,
 			"message": "No refresh token",
 		})
 	}
 
-	// Parse and validate refresh token
-	token, err := jwt.ParseWithClaims(refreshTokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.cfg.JWTSecret), nil
-	})
-
-	if err != nil || !token.Valid {
+	next, session, err := h.sessions.Rotate(refreshToken, c.Get("User-Agent"), c.IP())
+	if err != nil {
+		clearRefreshCookie(c)
+		message := "Invalid refresh token"
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			message = "Refresh token was already used, session has been revoked"
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid refresh token",
+			"message": message,
 		})
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || claims.Issuer != "eman-backend-refresh" {
+	// Disabled accounts can no longer refresh; the role is re-read so a
+	// changed role is picked up by the new access token.
+	var admin models.AdminUser
+	if err := database.DB.First(&admin, session.AdminUserID).Error; err != nil || admin.Disabled {
+		h.sessions.Revoke(session.ID, models.SessionRevokedAccountDisable)
+		clearRefreshCookie(c)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error":   true,
-			"message": "Invalid refresh token claims",
+			"message": "Account is disabled or no longer exists",
 		})
 	}
 
-	// Generate new access token
-	expiresAt := time.Now().Add(time.Duration(h.cfg.JWTExpiry) * time.Minute)
-	newClaims := Claims{
-		Username: claims.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "eman-backend",
-		},
+	// Sessions started before 2FA became mandatory must sign in again
+	if !admin.TOTPEnabled && h.twoFactor.Required() {
+		h.sessions.Revoke(session.ID, models.SessionRevokedByUser)
+		clearRefreshCookie(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor authentication is required, please sign in again",
+		})
 	}
 
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	tokenString, err := newToken.SignedString([]byte(h.cfg.JWTSecret))
+	tokenString, expiresAt, err := h.issueAccessToken(&admin, session.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -265,23 +365,22 @@ func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
 		})
 	}
 
+	setRefreshCookie(c, next, session.ExpiresAt)
+
 	return c.JSON(LoginResponse{
 		Token:     tokenString,
 		ExpiresAt: expiresAt.Unix(),
 	})
 }
 
-// Logout clears the refresh token cookie
+// Logout revokes the current session and clears the refresh token cookie
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	c.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HTTPOnly: true,
-		Secure:   false,
-		SameSite: "Lax",
-		Path:     "/api/auth",
-	})
+	if refreshToken := c.Cookies("refresh_token"); refreshToken != "" {
+		if session, err := h.sessions.SessionForToken(refreshToken); err == nil {
+			h.sessions.Revoke(session.ID, models.SessionRevokedLogout)
+		}
+	}
+	clearRefreshCookie(c)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -289,6 +388,12 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	})
 }
 
+// JWKS publishes the public signing keys
+func (h *AuthHandler) JWKS(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(h.keys.JWKS())
+}
+
 // Me returns current admin info
 func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	username := c.Locals("username")
@@ -299,7 +404,17 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"username": username,
-	})
+	role, _ := c.Locals("role").(string)
+
+	response := fiber.Map{
+		"username":    username,
+		"role":        role,
+		"permissions": models.RolePermissions(role),
+	}
+	var admin models.AdminUser
+	if err := database.DB.First(&admin, currentAdminID(c)).Error; err == nil {
+		response["email"] = admin.Email
+		response["telegram_chat_id"] = admin.TelegramChatID
+	}
+	return c.JSON(response)
 }
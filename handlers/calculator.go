@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errEstatePriceUnknown = errors.New("estate price not found")
+
+type CalculatorHandler struct {
+	macroService *services.MacroService
+}
+
+func NewCalculatorHandler(macroService *services.MacroService) *CalculatorHandler {
+	return &CalculatorHandler{macroService: macroService}
+}
+
+// PaymentCalculationRequest selects a price (directly or via a Macro estate)
+// and a plan from the payment_plans setting.
+type PaymentCalculationRequest struct {
+	EstateID           *int    `json:"estate_id"`
+	Price              float64 `json:"price"`
+	Plan               string  `json:"plan"`
+	PlanIndex          int     `json:"plan_index"`
+	Lang               string  `json:"lang"`
+	DownPaymentPercent float64 `json:"down_payment_percent"`
+	TermMonths         int     `json:"term_months"`
+}
+
+// paymentPlansKey maps a language to its payment_plans setting key.
+func paymentPlansKey(lang string) string {
+	switch strings.ToLower(strings.TrimSpace(lang)) {
+	case "uz":
+		return "payment_plans_uz"
+	case "en":
+		return "payment_plans_en"
+	default:
+		return "payment_plans"
+	}
+}
+
+func loadPaymentPlans(lang string) ([]services.PaymentPlan, error) {
+	var setting models.SiteSetting
+	if err := database.DB.Where("key = ?", paymentPlansKey(lang)).First(&setting).Error; err != nil {
+		return nil, fmt.Errorf("payment plans are not configured")
+	}
+	return services.ParsePaymentPlans(setting.Value)
+}
+
+// calculatePayment resolves the price and plan of a request and runs the calculator.
+func calculatePayment(macroService *services.MacroService, req PaymentCalculationRequest) (*services.PaymentCalculation, error) {
+	price := req.Price
+	if req.EstateID != nil && *req.EstateID > 0 {
+		estatePrice, ok := macroService.GetEstatePriceByID(*req.EstateID)
+		if !ok {
+			return nil, errEstatePriceUnknown
+		}
+		price = estatePrice
+	}
+
+	plans, err := loadPaymentPlans(req.Lang)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := services.FindPaymentPlan(plans, req.Plan, req.PlanIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := services.CalculatePayment(*plan, services.PaymentCalculationInput{
+		Price:              price,
+		DownPaymentPercent: req.DownPaymentPercent,
+		TermMonths:         req.TermMonths,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.EstateID != nil && *req.EstateID > 0 {
+		result.EstateID = req.EstateID
+	}
+	return result, nil
+}
+
+// Calculate returns the monthly payment and amortization schedule (public endpoint)
+func (h *CalculatorHandler) Calculate(c *fiber.Ctx) error {
+	var req PaymentCalculationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	if (req.EstateID == nil || *req.EstateID <= 0) && req.Price <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "estate_id or price is required",
+		})
+	}
+
+	result, err := calculatePayment(h.macroService, req)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if errors.Is(err, errEstatePriceUnknown) {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
@@ -14,10 +14,11 @@ import (
 
 type ChallengesHandler struct {
 	storage *services.StorageService
+	media   *services.MediaService
 }
 
-func NewChallengesHandler(storage *services.StorageService) *ChallengesHandler {
-	return &ChallengesHandler{storage: storage}
+func NewChallengesHandler(storage *services.StorageService, media *services.MediaService) *ChallengesHandler {
+	return &ChallengesHandler{storage: storage, media: media}
 }
 
 // ============ ADMIN ENDPOINTS ============
@@ -111,6 +112,8 @@ func (h *ChallengesHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentChallenge, item.ID, services.MediaFields{"image": item.Image})
+
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
@@ -163,6 +166,8 @@ func (h *ChallengesHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentChallenge, item.ID, services.MediaFields{"image": item.Image})
+
 	return c.JSON(item)
 }
 
@@ -191,6 +196,8 @@ func (h *ChallengesHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.ReleaseID(services.ContentChallenge, uint(id))
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Challenge deleted",
@@ -199,7 +206,7 @@ func (h *ChallengesHandler) Delete(c *fiber.Ctx) error {
 
 // Upload handles image upload for challenge
 func (h *ChallengesHandler) Upload(c *fiber.Ctx) error {
-	relativePath, err := uploadFromRequest(c, h.storage)
+	stored, err := uploadFromRequest(c, h.storage, h.media)
 	if err != nil {
 		message := err.Error()
 		if errors.Is(err, errNoFileUploaded) {
@@ -211,11 +218,9 @@ func (h *ChallengesHandler) Upload(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"url":     "/uploads/" + relativePath,
-		"path":    relativePath,
-	})
+	response := uploadedFile(h.storage, stored)
+	response["success"] = true
+	return c.JSON(response)
 }
 
 // Participants returns all participants of a challenge (admin)
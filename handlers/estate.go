@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"eman-backend/services"
+	"encoding/json"
 	"net/url"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,10 +10,16 @@ import (
 
 type EstateHandler struct {
 	macroService *services.MacroService
+	currency     *services.CurrencyService
+	reservations *services.ReservationService
 }
 
-func NewEstateHandler(macroService *services.MacroService) *EstateHandler {
-	return &EstateHandler{macroService: macroService}
+func NewEstateHandler(macroService *services.MacroService, currency *services.CurrencyService, reservations *services.ReservationService) *EstateHandler {
+	return &EstateHandler{
+		macroService: macroService,
+		currency:     currency,
+		reservations: reservations,
+	}
 }
 
 // GetComplexes возвращает список жилых комплексов
@@ -61,7 +68,30 @@ func (h *EstateHandler) GetEstates(c *fiber.Ctx) error {
 		}
 	})
 
-	data, err := h.macroService.GetEstates(params)
+	// Цены в ответе и фильтры price_from/price_to — в запрошенной валюте
+	currency := services.NormalizeCurrency(c.Query("currency"))
+	if currency != "" {
+		if _, ok := h.currency.Rate(currency); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Unknown currency: " + currency,
+			})
+		}
+		for _, key := range []string{"price_from", "price_to"} {
+			for i, value := range params[key] {
+				converted, err := h.currency.ToBase(value, currency)
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error":   true,
+						"message": "Invalid " + key + ": " + err.Error(),
+					})
+				}
+				params[key][i] = converted
+			}
+		}
+	}
+
+	estates, err := h.macroService.ListEstates(params)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   true,
@@ -69,6 +99,27 @@ func (h *EstateHandler) GetEstates(c *fiber.Ctx) error {
 		})
 	}
 
+	if currency != "" {
+		estates, err = h.currency.ConvertEstatePrices(estates, currency)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+	}
+
+	// Отмечаем забронированные квартиры
+	estates = h.reservations.MarkReservedEstates(estates)
+
+	data, err := json.Marshal(estates)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to encode estates",
+		})
+	}
+
 	c.Set("Content-Type", "application/json")
 	return c.Send(data)
 }
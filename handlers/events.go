@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"eman-backend/middleware"
+	ws "eman-backend/websocket"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Comment lines keep proxies from closing an idle stream
+const sseHeartbeat = 20 * time.Second
+
+// Stream is the Server-Sent Events fallback for networks that block
+// WebSocket upgrades. It carries the same messages as /ws: authenticate with
+// ?token= or an Authorization header, pick topics with ?topics=, and resume
+// with Last-Event-ID. An EventSource cannot send a fresh token, so when the
+// token expires the stream says "auth_expired" and closes; the client
+// reconnects with a new token and resumes.
+func (h *WebSocketHandler) Stream(c *fiber.Ctx) error {
+	client := ws.NewStreamClient(h.hub)
+	var authExpiresAt time.Time
+
+	token := c.Query("token")
+	if header := c.Get("Authorization"); token == "" && strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	if token != "" {
+		identity, msg := middleware.VerifyAccessToken(h.keys, token)
+		if identity == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": msg,
+			})
+		}
+		client.Authenticate(identity.Username, identity.Role, identity.ExpiresAt)
+		authExpiresAt = identity.ExpiresAt
+	}
+
+	topics := splitTopics(c.Query("topics"))
+	if len(topics) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "At least one topic is required",
+		})
+	}
+	h.subscribe(client, topics)
+
+	// Event ids are "<epoch>:<seq>"; EventSource sends the last one back on reconnect
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	epoch, lastSeq, resume := strings.Cut(lastEventID, ":")
+	if !resume && c.Query("last_seq") != "" {
+		epoch, lastSeq, resume = c.Query("epoch"), c.Query("last_seq"), true
+	}
+
+	h.hub.Register(client)
+	if resume {
+		if seq, err := strconv.ParseUint(lastSeq, 10, 64); err == nil {
+			h.hub.Resume(client, epoch, seq)
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.hub.Unregister(client)
+
+		ticker := time.NewTicker(sseHeartbeat)
+		defer ticker.Stop()
+
+		var authExpired <-chan time.Time
+		if !authExpiresAt.IsZero() {
+			timer := time.NewTimer(time.Until(authExpiresAt))
+			defer timer.Stop()
+			authExpired = timer.C
+		}
+
+		w.WriteString("retry: 3000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case data, ok := <-client.Outgoing():
+				if !ok {
+					return
+				}
+				h.writeEvent(w, data)
+			case <-ticker.C:
+				w.WriteString(": heartbeat\n\n")
+			case <-authExpired:
+				data, _ := json.Marshal(ws.Message{Type: "auth_expired", Payload: fiber.Map{
+					"message": "Access token expired, reconnect with a fresh token",
+				}})
+				h.writeEvent(w, data)
+				w.Flush()
+				return
+			}
+			// A failed flush means the client has gone away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeEvent writes one hub frame as an unnamed SSE event, so a single
+// onmessage handler sees the same JSON a WebSocket client would.
+func (h *WebSocketHandler) writeEvent(w *bufio.Writer, data []byte) {
+	var head struct {
+		Seq uint64 `json:"seq"`
+	}
+	if json.Unmarshal(data, &head) == nil && head.Seq > 0 {
+		w.WriteString("id: " + h.hub.Epoch() + ":" + strconv.FormatUint(head.Seq, 10) + "\n")
+	}
+	w.WriteString("data: ")
+	w.Write(data)
+	w.WriteString("\n\n")
+}
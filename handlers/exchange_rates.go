@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExchangeRatesHandler struct {
+	currency *services.CurrencyService
+}
+
+func NewExchangeRatesHandler(currency *services.CurrencyService) *ExchangeRatesHandler {
+	return &ExchangeRatesHandler{currency: currency}
+}
+
+// List returns all stored exchange rates
+func (h *ExchangeRatesHandler) List(c *fiber.Ctx) error {
+	var items []models.ExchangeRate
+	if err := database.DB.Order("currency ASC").Find(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch exchange rates",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"base":  h.currency.BaseCurrency(),
+		"items": items,
+		"total": len(items),
+	})
+}
+
+type UpdateExchangeRateRequest struct {
+	Rate float64 `json:"rate"`
+}
+
+// Update sets a manual rate for a currency (admin)
+func (h *ExchangeRatesHandler) Update(c *fiber.Ctx) error {
+	var req UpdateExchangeRateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	item, err := h.currency.SetRate(c.Params("currency"), req.Rate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(item)
+}
+
+// Delete removes a currency (admin)
+func (h *ExchangeRatesHandler) Delete(c *fiber.Ctx) error {
+	deleted, err := h.currency.DeleteRate(c.Params("currency"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to delete exchange rate",
+		})
+	}
+
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Exchange rate not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Exchange rate deleted",
+	})
+}
+
+// Import pulls rates from the configured source right away (admin)
+func (h *ExchangeRatesHandler) Import(c *fiber.Ctx) error {
+	count, err := h.currency.Import()
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Exchange rates imported",
+		"count":   count,
+	})
+}
@@ -12,10 +12,12 @@ import (
 
 type GalleryHandler struct {
 	storage *services.StorageService
+	content *services.ContentEventService
+	media   *services.MediaService
 }
 
-func NewGalleryHandler(storage *services.StorageService) *GalleryHandler {
-	return &GalleryHandler{storage: storage}
+func NewGalleryHandler(storage *services.StorageService, content *services.ContentEventService, media *services.MediaService) *GalleryHandler {
+	return &GalleryHandler{storage: storage, content: content, media: media}
 }
 
 // List returns all gallery items (admin)
@@ -160,6 +162,9 @@ func (h *GalleryHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentGalleryItem, item.ID, services.MediaFields{"url": item.URL, "thumbnail": item.Thumbnail})
+	h.content.EmitID(services.ContentGalleryItem, services.ContentCreated, item.ID)
+
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
@@ -217,6 +222,9 @@ func (h *GalleryHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentGalleryItem, item.ID, services.MediaFields{"url": item.URL, "thumbnail": item.Thumbnail})
+	h.content.EmitID(services.ContentGalleryItem, services.ContentUpdated, item.ID)
+
 	return c.JSON(item)
 }
 
@@ -245,6 +253,9 @@ func (h *GalleryHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.ReleaseID(services.ContentGalleryItem, uint(id))
+	h.content.EmitID(services.ContentGalleryItem, services.ContentDeleted, uint(id))
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Gallery item deleted",
@@ -277,6 +288,12 @@ func (h *GalleryHandler) Reorder(c *fiber.Ctx) error {
 		}
 	}
 
+	ids := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		ids = append(ids, strconv.FormatUint(uint64(item.ID), 10))
+	}
+	h.content.Emit(services.ContentGalleryItem, services.ContentReordered, ids...)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Items reordered",
@@ -285,7 +302,7 @@ func (h *GalleryHandler) Reorder(c *fiber.Ctx) error {
 
 // Upload handles file upload
 func (h *GalleryHandler) Upload(c *fiber.Ctx) error {
-	relativePath, err := uploadFromRequest(c, h.storage)
+	stored, err := uploadFromRequest(c, h.storage, h.media)
 	if err != nil {
 		message := err.Error()
 		if errors.Is(err, errNoFileUploaded) {
@@ -298,9 +315,7 @@ func (h *GalleryHandler) Upload(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"url":     "/uploads/" + relativePath,
-		"path":    relativePath,
-	})
+	response := uploadedFile(h.storage, stored)
+	response["success"] = true
+	return c.JSON(response)
 }
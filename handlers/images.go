@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"eman-backend/services"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImageHandler struct {
+	storage *services.StorageService
+	images  *services.ImageService
+}
+
+func NewImageHandler(storage *services.StorageService, images *services.ImageService) *ImageHandler {
+	return &ImageHandler{storage: storage, images: images}
+}
+
+// Resize serves /img/{w}x{h}/{path} as WebP. Only sizes listed in
+// IMAGE_RESIZE_SIZES are rendered; animated GIFs redirect to the original.
+func (h *ImageHandler) Resize(c *fiber.Ctx) error {
+	size := c.Params("size")
+	key := c.Params("*")
+
+	cachePath, err := h.images.Resize(size, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrImageSizeNotAllowed):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Image size not allowed",
+			})
+		case errors.Is(err, services.ErrNotAnImage):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "File cannot be resized",
+			})
+		case errors.Is(err, services.ErrObjectNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   true,
+				"message": "Image not found",
+			})
+		case errors.Is(err, services.ErrImageAnimated):
+			return c.Redirect(h.storage.URL(key), fiber.StatusFound)
+		default:
+			log.Printf("[Images] failed to resize %s to %s: %v", key, size, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to resize image",
+			})
+		}
+	}
+
+	c.Set("Cache-Control", "public, max-age=31536000, immutable")
+	return c.SendFile(cachePath)
+}
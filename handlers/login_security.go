@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type LoginSecurityHandler struct {
+	loginGuard *services.LoginGuardService
+}
+
+func NewLoginSecurityHandler(loginGuard *services.LoginGuardService) *LoginSecurityHandler {
+	return &LoginSecurityHandler{loginGuard: loginGuard}
+}
+
+// Lockouts returns usernames and IPs that are currently locked out (owner)
+func (h *LoginSecurityHandler) Lockouts(c *fiber.Ctx) error {
+	items, err := h.loginGuard.ActiveLockouts()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch lockouts",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// ClearLockout lifts a lockout and resets its failure counter (owner)
+func (h *LoginSecurityHandler) ClearLockout(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	if err := h.loginGuard.Clear(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   true,
+				"message": "Lockout not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to clear lockout",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Lockout cleared",
+	})
+}
+
+// Attempts returns recent login attempts, newest first (owner)
+func (h *LoginSecurityHandler) Attempts(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Model(&models.LoginAttempt{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true" || success == "1")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.LoginAttempt
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch login attempts",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
@@ -12,16 +12,20 @@ import (
 
 type MapIconTypeHandler struct {
 	storage *services.StorageService
+	content *services.ContentEventService
+	media   *services.MediaService
 }
 
-func NewMapIconTypeHandler(storage *services.StorageService) *MapIconTypeHandler {
-	return &MapIconTypeHandler{storage: storage}
+func NewMapIconTypeHandler(storage *services.StorageService, content *services.ContentEventService, media *services.MediaService) *MapIconTypeHandler {
+	return &MapIconTypeHandler{storage: storage, content: content, media: media}
 }
 
-type MapIconHandler struct{}
+type MapIconHandler struct {
+	content *services.ContentEventService
+}
 
-func NewMapIconHandler() *MapIconHandler {
-	return &MapIconHandler{}
+func NewMapIconHandler(content *services.ContentEventService) *MapIconHandler {
+	return &MapIconHandler{content: content}
 }
 
 func hydrateMapIconTypeNames(item *models.MapIconType) {
@@ -125,6 +129,9 @@ func (h *MapIconTypeHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentMapIconType, item.ID, services.MediaFields{"icon": item.Icon})
+	h.content.EmitID(services.ContentMapIconType, services.ContentCreated, item.ID)
+
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
@@ -183,6 +190,9 @@ func (h *MapIconTypeHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentMapIconType, item.ID, services.MediaFields{"icon": item.Icon})
+	h.content.EmitID(services.ContentMapIconType, services.ContentUpdated, item.ID)
+
 	return c.JSON(item)
 }
 
@@ -225,6 +235,9 @@ func (h *MapIconTypeHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.ReleaseID(services.ContentMapIconType, uint(id))
+	h.content.EmitID(services.ContentMapIconType, services.ContentDeleted, uint(id))
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Map icon type deleted",
@@ -233,7 +246,7 @@ func (h *MapIconTypeHandler) Delete(c *fiber.Ctx) error {
 
 // Upload handles icon upload
 func (h *MapIconTypeHandler) Upload(c *fiber.Ctx) error {
-	relativePath, err := uploadFromRequest(c, h.storage)
+	stored, err := uploadFromRequest(c, h.storage, h.media)
 	if err != nil {
 		message := err.Error()
 		if errors.Is(err, errNoFileUploaded) {
@@ -246,11 +259,9 @@ func (h *MapIconTypeHandler) Upload(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"url":     "/uploads/" + relativePath,
-		"path":    relativePath,
-	})
+	response := uploadedFile(h.storage, stored)
+	response["success"] = true
+	return c.JSON(response)
 }
 
 // ===== Map Icons (markers) =====
@@ -347,6 +358,8 @@ func (h *MapIconHandler) Create(c *fiber.Ctx) error {
 
 	hydrateMapIconNames(&item)
 
+	h.content.EmitID(services.ContentMapIcon, services.ContentCreated, item.ID)
+
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
@@ -423,6 +436,8 @@ func (h *MapIconHandler) Update(c *fiber.Ctx) error {
 
 	hydrateMapIconNames(&item)
 
+	h.content.EmitID(services.ContentMapIcon, services.ContentUpdated, item.ID)
+
 	return c.JSON(item)
 }
 
@@ -450,6 +465,8 @@ func (h *MapIconHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
+	h.content.EmitID(services.ContentMapIcon, services.ContentDeleted, uint(id))
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Map icon deleted",
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type MediaHandler struct {
+	storage *services.StorageService
+	media   *services.MediaService
+}
+
+func NewMediaHandler(storage *services.StorageService, media *services.MediaService) *MediaHandler {
+	return &MediaHandler{storage: storage, media: media}
+}
+
+func (h *MediaHandler) fillURLs(item *models.MediaAsset) {
+	if item.ScanStatus == models.ScanInfected {
+		return // quarantined files have no public URL
+	}
+	item.URL = h.storage.URL(item.Path)
+	for i := range item.Variants {
+		item.Variants[i].URL = h.storage.URL(item.Variants[i].Path)
+	}
+}
+
+// mediaKinds maps the ?type= filter to MIME type patterns
+var mediaKinds = map[string]string{
+	"image":    "image/%",
+	"video":    "video/%",
+	"audio":    "audio/%",
+	"document": "application/%",
+}
+
+// List browses the media library, newest first (admin)
+func (h *MediaHandler) List(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Model(&models.MediaAsset{})
+	if kind := c.Query("type"); kind != "" {
+		pattern, ok := mediaKinds[kind]
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Type must be image, video, audio or document",
+			})
+		}
+		query = query.Where("mime_type LIKE ?", pattern)
+	}
+	if scanStatus := c.Query("scan_status"); scanStatus != "" {
+		query = query.Where("scan_status = ?", scanStatus)
+	}
+	if uploadedBy := c.Query("uploaded_by"); uploadedBy != "" {
+		query = query.Where("uploaded_by = ?", uploadedBy)
+	}
+	switch c.Query("unused") {
+	case "true":
+		query = query.Where("ref_count = 0")
+	case "false":
+		query = query.Where("ref_count > 0")
+	}
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("original_name ILIKE ? OR path ILIKE ?", like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.MediaAsset
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch media",
+		})
+	}
+	for i := range items {
+		h.fillURLs(&items[i])
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// Get returns an asset with the entities that use it (admin)
+func (h *MediaHandler) Get(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	var item models.MediaAsset
+	if err := database.DB.First(&item, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Media asset not found",
+		})
+	}
+	h.fillURLs(&item)
+
+	var references []models.MediaReference
+	database.DB.Where("asset_id = ?", item.ID).Order("entity_type, entity_id").Find(&references)
+
+	return c.JSON(fiber.Map{
+		"asset":      item,
+		"references": references,
+	})
+}
+
+// Delete removes an asset that nothing references (admin)
+func (h *MediaHandler) Delete(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	if err := h.media.Delete(uint(id)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   true,
+				"message": "Media asset not found",
+			})
+		case errors.Is(err, services.ErrMediaInUse):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": "Media asset is still in use",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to delete media asset",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Media asset deleted",
+	})
+}
+
+// Quarantine lists uploads the malware scanner flagged (admin)
+func (h *MediaHandler) Quarantine(c *fiber.Ctx) error {
+	var items []models.MediaAsset
+	if err := database.DB.Where("scan_status = ?", models.ScanInfected).
+		Order("created_at DESC").
+		Find(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch quarantined media",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// Restore releases a quarantined file judged a false positive (admin)
+func (h *MediaHandler) Restore(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	item, err := h.media.Restore(uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   true,
+				"message": "Media asset not found",
+			})
+		case errors.Is(err, services.ErrMediaNotQuarantined):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": "Media asset is not quarantined",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to restore media asset",
+			})
+		}
+	}
+
+	return c.JSON(item)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OfferHandler struct {
+	macroService *services.MacroService
+	offers       *services.CommercialOfferService
+}
+
+func NewOfferHandler(macroService *services.MacroService, offers *services.CommercialOfferService) *OfferHandler {
+	return &OfferHandler{
+		macroService: macroService,
+		offers:       offers,
+	}
+}
+
+// settingValues loads the given setting keys into a key -> value map.
+func settingValues(keys ...string) map[string]string {
+	var settings []models.SiteSetting
+	values := make(map[string]string, len(keys))
+	if err := database.DB.Where("key IN ?", keys).Find(&settings).Error; err != nil {
+		return values
+	}
+	for _, s := range settings {
+		values[s.Key] = s.Value
+	}
+	return values
+}
+
+// localizedSetting returns key_<lang> when present, falling back to the base key.
+func localizedSetting(values map[string]string, key, lang string) string {
+	if lang != "" && lang != "ru" {
+		if v := strings.TrimSpace(values[key+"_"+lang]); v != "" {
+			return v
+		}
+	}
+	return strings.TrimSpace(values[key])
+}
+
+// Download renders a commercial offer PDF for a Macro estate (public endpoint)
+func (h *OfferHandler) Download(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	estate := h.macroService.GetEstateByID(id)
+	if estate == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Estate not found",
+		})
+	}
+
+	lang := services.NormalizeOfferLang(c.Query("lang"))
+
+	var calculation *services.PaymentCalculation
+	if c.Query("plan") != "" || c.Query("plan_index") != "" {
+		calculation, err = calculatePayment(h.macroService, PaymentCalculationRequest{
+			EstateID:           &id,
+			Plan:               c.Query("plan"),
+			PlanIndex:          c.QueryInt("plan_index", 0),
+			Lang:               lang,
+			DownPaymentPercent: c.QueryFloat("down_payment_percent", 0),
+			TermMonths:         c.QueryInt("term_months", 0),
+		})
+		if err != nil {
+			status := fiber.StatusBadRequest
+			if errors.Is(err, errEstatePriceUnknown) {
+				status = fiber.StatusNotFound
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+	}
+
+	values := settingValues(
+		"hero_title",
+		"phone",
+		"address", "address_uz", "address_en",
+		"working_hours", "working_hours_uz", "working_hours_en",
+	)
+
+	pdf, err := h.offers.RenderPDF(services.CommercialOffer{
+		Lang:     lang,
+		Brand:    values["hero_title"],
+		EstateID: id,
+		Estate:   estate,
+		Contacts: services.OfferContacts{
+			Phone:        localizedSetting(values, "phone", lang),
+			Address:      localizedSetting(values, "address", lang),
+			WorkingHours: localizedSetting(values, "working_hours", lang),
+		},
+		Calculation: calculation,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to generate offer",
+		})
+	}
+
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="offer-%d-%s.pdf"`, id, lang))
+	return c.Send(pdf)
+}
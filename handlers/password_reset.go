@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"log"
+	"net/mail"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+	Channel  string `json:"channel"` // telegram, email; optional
+}
+
+type PasswordResetConfirmRequest struct {
+	Token           string `json:"token"`
+	NewPassword     string `json:"new_password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+// RequestPasswordReset sends a reset link to the admin's linked Telegram chat
+// or email. The response never reveals whether the account exists.
+func (h *AuthHandler) RequestPasswordReset(c *fiber.Ctx) error {
+	var req PasswordResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Username is required",
+		})
+	}
+	if req.Channel != "" && req.Channel != models.ResetChannelTelegram && req.Channel != models.ResetChannelEmail {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Channel must be telegram or email",
+		})
+	}
+
+	ip := c.IP()
+	userAgent := c.Get("User-Agent")
+
+	// Only the IP is checked: a locked-out username is exactly who needs a reset
+	if blocked, err := h.rejectThrottled(c, "", ip, userAgent); blocked {
+		return err
+	}
+
+	var admin models.AdminUser
+	if err := database.DB.Where("username = ?", req.Username).First(&admin).Error; err != nil {
+		h.loginGuard.RecordFailure(req.Username, ip, userAgent, models.LoginReasonResetUnavailable)
+	} else {
+		switch err := h.resets.Request(&admin, req.Channel, ip); {
+		case err == nil:
+			h.loginGuard.RecordAttempt(admin.Username, ip, userAgent, true, models.LoginReasonResetRequested)
+		case errors.Is(err, services.ErrPasswordResetTooSoon):
+			h.loginGuard.RecordAttempt(admin.Username, ip, userAgent, false, models.LoginReasonThrottled)
+		case errors.Is(err, services.ErrPasswordResetUnavailable):
+			h.loginGuard.RecordAttempt(admin.Username, ip, userAgent, false, models.LoginReasonResetUnavailable)
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to request password reset",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "If the account has Telegram or email linked, a reset link has been sent",
+	})
+}
+
+// ConfirmPasswordReset sets a new password with a reset token
+func (h *AuthHandler) ConfirmPasswordReset(c *fiber.Ctx) error {
+	var req PasswordResetConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" || req.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Token and new password are required",
+		})
+	}
+	if msg := passwordPolicyError(req.NewPassword); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": msg,
+		})
+	}
+	if req.ConfirmPassword != "" && req.ConfirmPassword != req.NewPassword {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Password confirmation does not match",
+		})
+	}
+
+	ip := c.IP()
+	userAgent := c.Get("User-Agent")
+
+	if blocked, err := h.rejectThrottled(c, "", ip, userAgent); blocked {
+		return err
+	}
+
+	admin, err := h.resets.Confirm(req.Token, req.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasswordResetInvalid):
+			// Guessing tokens counts against the IP like wrong passwords do
+			h.loginGuard.RecordFailure("", ip, userAgent, models.LoginReasonResetInvalidToken)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "Invalid or expired reset token",
+			})
+		case errors.Is(err, services.ErrPasswordUnchanged):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   true,
+				"message": "New password must be different from current password",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to reset password",
+			})
+		}
+	}
+
+	h.loginGuard.RecordAttempt(admin.Username, ip, userAgent, true, models.LoginReasonResetCompleted)
+	h.loginGuard.ClearUsername(admin.Username)
+	clearRefreshCookie(c)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Password updated, please sign in",
+	})
+}
+
+type UpdateContactRequest struct {
+	Email          *string `json:"email"`
+	TelegramChatID *string `json:"telegram_chat_id"`
+
+	// Re-authentication: these fields decide where reset links go
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// contactUpdates validates the reset delivery fields of a request.
+func contactUpdates(req UpdateContactRequest) (map[string]interface{}, string) {
+	updates := map[string]interface{}{}
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email != "" {
+			addr, err := mail.ParseAddress(email)
+			if err != nil || addr.Address != email {
+				return nil, "Invalid email address"
+			}
+		}
+		updates["email"] = email
+	}
+	if req.TelegramChatID != nil {
+		chatID := strings.TrimSpace(*req.TelegramChatID)
+		if strings.ContainsAny(chatID, " \t\r\n") {
+			return nil, "Invalid Telegram chat ID"
+		}
+		updates["telegram_chat_id"] = chatID
+	}
+	return updates, ""
+}
+
+// UpdateContact links the admin's own Telegram chat and email for password
+// resets (protected). A stolen session must not be enough to redirect reset
+// links, so the current password and, with 2FA on, a code are required.
+func (h *AuthHandler) UpdateContact(c *fiber.Ctx) error {
+	admin, err := currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	var req UpdateContactRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+	updates, msg := contactUpdates(req)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": msg,
+		})
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Current password is incorrect",
+		})
+	}
+	if admin.TOTPEnabled {
+		var err error
+		if strings.TrimSpace(req.RecoveryCode) != "" {
+			err = h.twoFactor.UseRecoveryCode(admin, req.RecoveryCode)
+		} else {
+			err = h.twoFactor.Verify(admin, req.Code)
+		}
+		if err != nil {
+			return twoFactorError(c, err)
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(admin).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to update contact details",
+			})
+		}
+		// Links already sent went to the old address
+		if err := h.resets.RevokeOutstanding(admin.ID); err != nil {
+			log.Printf("[Auth] failed to revoke reset tokens of %s: %v", admin.Username, err)
+		}
+		database.DB.First(admin, admin.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"email":            admin.Email,
+		"telegram_chat_id": admin.TelegramChatID,
+	})
+}
@@ -12,10 +12,12 @@ import (
 
 type ProjectsHandler struct {
 	storage *services.StorageService
+	content *services.ContentEventService
+	media   *services.MediaService
 }
 
-func NewProjectsHandler(storage *services.StorageService) *ProjectsHandler {
-	return &ProjectsHandler{storage: storage}
+func NewProjectsHandler(storage *services.StorageService, content *services.ContentEventService, media *services.MediaService) *ProjectsHandler {
+	return &ProjectsHandler{storage: storage, content: content, media: media}
 }
 
 // List returns all projects (admin)
@@ -128,6 +130,9 @@ func (h *ProjectsHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentProject, item.ID, services.MediaFields{"image": item.Image})
+	h.content.EmitID(services.ContentProject, services.ContentCreated, item.ID)
+
 	return c.Status(fiber.StatusCreated).JSON(item)
 }
 
@@ -177,6 +182,9 @@ func (h *ProjectsHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.TrackID(services.ContentProject, item.ID, services.MediaFields{"image": item.Image})
+	h.content.EmitID(services.ContentProject, services.ContentUpdated, item.ID)
+
 	return c.JSON(item)
 }
 
@@ -205,6 +213,9 @@ func (h *ProjectsHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
+	h.media.ReleaseID(services.ContentProject, uint(id))
+	h.content.EmitID(services.ContentProject, services.ContentDeleted, uint(id))
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Project deleted",
@@ -213,7 +224,7 @@ func (h *ProjectsHandler) Delete(c *fiber.Ctx) error {
 
 // Upload handles file upload for project images
 func (h *ProjectsHandler) Upload(c *fiber.Ctx) error {
-	relativePath, err := uploadFromRequest(c, h.storage)
+	stored, err := uploadFromRequest(c, h.storage, h.media)
 	if err != nil {
 		message := err.Error()
 		if errors.Is(err, errNoFileUploaded) {
@@ -226,9 +237,7 @@ func (h *ProjectsHandler) Upload(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"url":     "/uploads/" + relativePath,
-		"path":    relativePath,
-	})
+	response := uploadedFile(h.storage, stored)
+	response["success"] = true
+	return c.JSON(response)
 }
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ReservationsHandler struct {
+	reservations *services.ReservationService
+	macroService *services.MacroService
+}
+
+func NewReservationsHandler(reservations *services.ReservationService, macroService *services.MacroService) *ReservationsHandler {
+	return &ReservationsHandler{
+		reservations: reservations,
+		macroService: macroService,
+	}
+}
+
+func currentUsername(c *fiber.Ctx) string {
+	username, _ := c.Locals("username").(string)
+	return username
+}
+
+// List returns reservations, newest first (admin)
+func (h *ReservationsHandler) List(c *fiber.Ctx) error {
+	var items []models.Reservation
+
+	query := database.DB.Preload("Submission").Order("created_at DESC")
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if estateID := c.QueryInt("estate_id", 0); estateID > 0 {
+		query = query.Where("estate_id = ?", estateID)
+	}
+
+	if err := query.Find(&items).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch reservations",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+type CreateReservationRequest struct {
+	EstateID     int    `json:"estate_id"`
+	Hours        int    `json:"hours"`
+	SubmissionID *uint  `json:"submission_id"`
+	Notes        string `json:"notes"`
+}
+
+// Create places a hold on an estate (admin)
+func (h *ReservationsHandler) Create(c *fiber.Ctx) error {
+	var req CreateReservationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.EstateID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "estate_id is required",
+		})
+	}
+
+	if h.macroService.GetEstateByID(req.EstateID) == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Estate not found",
+		})
+	}
+
+	if req.SubmissionID != nil {
+		var submission models.ContactSubmission
+		if err := database.DB.First(&submission, *req.SubmissionID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   true,
+				"message": "Submission not found",
+			})
+		}
+	}
+
+	item, err := h.reservations.Create(req.EstateID, req.Hours, currentUsername(c), req.SubmissionID, req.Notes)
+	if err != nil {
+		if errors.Is(err, services.ErrEstateAlreadyReserved) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(item)
+}
+
+type ExtendReservationRequest struct {
+	Hours int `json:"hours"`
+}
+
+// Extend prolongs an active hold (admin)
+func (h *ReservationsHandler) Extend(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	var req ExtendReservationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	item, err := h.reservations.Extend(uint(id), req.Hours, currentUsername(c))
+	if err != nil {
+		return reservationError(c, err)
+	}
+
+	return c.JSON(item)
+}
+
+// Release ends an active hold (admin)
+func (h *ReservationsHandler) Release(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	item, err := h.reservations.Release(uint(id), currentUsername(c))
+	if err != nil {
+		return reservationError(c, err)
+	}
+
+	return c.JSON(item)
+}
+
+func reservationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Reservation not found",
+		})
+	case errors.Is(err, services.ErrReservationNotActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eman-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,checksum,termination"
+
+	// Upload-Checksum mismatch, as defined by the tus checksum extension
+	statusChecksumMismatch = 460
+)
+
+// ResumableUploadHandler speaks the tus 1.0 protocol so large videos can be
+// uploaded in chunks and resumed after a dropped connection.
+type ResumableUploadHandler struct {
+	uploads *services.ResumableUploadService
+	storage *services.StorageService
+	media   *services.MediaService
+}
+
+func NewResumableUploadHandler(uploads *services.ResumableUploadService, storage *services.StorageService, media *services.MediaService) *ResumableUploadHandler {
+	return &ResumableUploadHandler{uploads: uploads, storage: storage, media: media}
+}
+
+func (h *ResumableUploadHandler) tusError(c *fiber.Ctx, status int, message string) error {
+	c.Set("Tus-Resumable", tusVersion)
+	return c.Status(status).JSON(fiber.Map{
+		"error":   true,
+		"message": message,
+	})
+}
+
+func (h *ResumableUploadHandler) sessionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUploadNotFound):
+		return h.tusError(c, fiber.StatusNotFound, "Upload not found")
+	case errors.Is(err, services.ErrUploadOffsetMismatch):
+		return h.tusError(c, fiber.StatusConflict, "Upload-Offset does not match the upload")
+	case errors.Is(err, services.ErrUploadTooLarge):
+		return h.tusError(c, fiber.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+	case errors.Is(err, services.ErrUploadChecksumMismatch):
+		return h.tusError(c, statusChecksumMismatch, "Checksum mismatch")
+	case errors.Is(err, services.ErrUploadCompleted):
+		return h.tusError(c, fiber.StatusGone, "Upload already completed")
+	case errors.Is(err, services.ErrUploadFailed), errors.As(err, new(*services.InfectedError)):
+		return h.tusError(c, fiber.StatusUnprocessableEntity, err.Error())
+	default:
+		return h.tusError(c, fiber.StatusInternalServerError, "Failed to process upload")
+	}
+}
+
+// parseUploadMetadata decodes "key base64value,key base64value".
+func parseUploadMetadata(header string) (map[string]string, bool) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, false
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, true
+}
+
+// Options advertises the supported protocol and extensions
+func (h *ResumableUploadHandler) Options(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(h.uploads.MaxSize(), 10))
+	c.Set("Tus-Checksum-Algorithm", "md5,sha1,sha256")
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Create starts an upload from Upload-Length and Upload-Metadata
+// (filename, filetype and an optional hex sha256 of the whole file)
+func (h *ResumableUploadHandler) Create(c *fiber.Ctx) error {
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		return h.tusError(c, fiber.StatusBadRequest, "Upload-Length required")
+	}
+	if length > h.uploads.MaxSize() {
+		return h.tusError(c, fiber.StatusRequestEntityTooLarge, "Upload-Length exceeds Tus-Max-Size")
+	}
+	metadata, ok := parseUploadMetadata(c.Get("Upload-Metadata"))
+	if !ok {
+		return h.tusError(c, fiber.StatusBadRequest, "Invalid Upload-Metadata")
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+
+	session, err := h.uploads.Create(length, filename, metadata["filetype"], strings.ToLower(metadata["sha256"]), currentUsername(c))
+	if err != nil {
+		return h.tusError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Location", c.BaseURL()+strings.TrimSuffix(c.Path(), "/")+"/"+session.ID)
+	c.Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// Head reports how much of an upload the server has
+func (h *ResumableUploadHandler) Head(c *fiber.Ctx) error {
+	session, err := h.uploads.Get(c.Params("id"))
+	if err != nil {
+		c.Set("Tus-Resumable", tusVersion)
+		if errors.Is(err, services.ErrUploadNotFound) {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Cache-Control", "no-store")
+	c.Set("Upload-Offset", strconv.FormatInt(session.Received, 10))
+	c.Set("Upload-Length", strconv.FormatInt(session.Length, 10))
+	c.Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// Get returns the upload's state, with the stored file once it is complete
+func (h *ResumableUploadHandler) Get(c *fiber.Ctx) error {
+	session, err := h.uploads.Get(c.Params("id"))
+	if err != nil {
+		return h.sessionError(c, err)
+	}
+
+	response := fiber.Map{"upload": session}
+	if session.ResultPath != "" {
+		response["url"] = h.storage.URL(session.ResultPath)
+		response["path"] = session.ResultPath
+	}
+	c.Set("Tus-Resumable", tusVersion)
+	return c.JSON(response)
+}
+
+// Patch appends a chunk at Upload-Offset. The last chunk assembles the file
+// and adds it to the media library.
+func (h *ResumableUploadHandler) Patch(c *fiber.Ctx) error {
+	if c.Get("Content-Type") != "application/offset+octet-stream" {
+		return h.tusError(c, fiber.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return h.tusError(c, fiber.StatusBadRequest, "Upload-Offset required")
+	}
+
+	var checksum *services.ChunkChecksum
+	if header := c.Get("Upload-Checksum"); header != "" {
+		algorithm, encoded, _ := strings.Cut(header, " ")
+		if _, ok := services.ChecksumAlgorithms[algorithm]; !ok {
+			return h.tusError(c, fiber.StatusBadRequest, "Unsupported checksum algorithm")
+		}
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return h.tusError(c, fiber.StatusBadRequest, "Invalid Upload-Checksum")
+		}
+		checksum = &services.ChunkChecksum{Algorithm: algorithm, Sum: sum}
+	}
+
+	size := int64(c.Request().Header.ContentLength())
+	var body io.Reader = c.Request().BodyStream()
+	if body == nil {
+		raw := c.Body()
+		body = bytes.NewReader(raw)
+		size = int64(len(raw))
+	}
+	if size < 0 {
+		return h.tusError(c, fiber.StatusLengthRequired, "Content-Length required")
+	}
+
+	session, stored, err := h.uploads.WriteChunk(c.Params("id"), offset, size, body, checksum)
+	if err != nil {
+		recordQuarantined(c, h.media, err)
+		return h.sessionError(c, err)
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Upload-Offset", strconv.FormatInt(session.Received, 10))
+	c.Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if stored != nil {
+		recordUpload(c, h.media, stored)
+		c.Set("Upload-Result-Path", stored.Path)
+		c.Set("Upload-Result-URL", h.storage.URL(stored.Path))
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Terminate cancels an upload and discards its chunks
+func (h *ResumableUploadHandler) Terminate(c *fiber.Ctx) error {
+	if err := h.uploads.Terminate(c.Params("id")); err != nil {
+		return h.sessionError(c, err)
+	}
+	c.Set("Tus-Resumable", tusVersion)
+	return c.SendStatus(fiber.StatusNoContent)
+}
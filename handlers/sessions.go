@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SessionsHandler struct {
+	sessions *services.SessionService
+}
+
+func NewSessionsHandler(sessions *services.SessionService) *SessionsHandler {
+	return &SessionsHandler{sessions: sessions}
+}
+
+func currentAdminID(c *fiber.Ctx) uint {
+	id, _ := c.Locals("admin_id").(uint)
+	return id
+}
+
+func currentSessionID(c *fiber.Ctx) uint {
+	id, _ := c.Locals("session_id").(uint)
+	return id
+}
+
+// List returns the signed-in admin's active sessions (protected)
+func (h *SessionsHandler) List(c *fiber.Ctx) error {
+	items, err := h.sessions.ActiveSessions(currentAdminID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to fetch sessions",
+		})
+	}
+
+	current := currentSessionID(c)
+	out := make([]fiber.Map, 0, len(items))
+	for _, item := range items {
+		out = append(out, fiber.Map{
+			"id":           item.ID,
+			"user_agent":   item.UserAgent,
+			"ip_address":   item.IPAddress,
+			"last_seen_at": item.LastSeenAt,
+			"expires_at":   item.ExpiresAt,
+			"created_at":   item.CreatedAt,
+			"current":      item.ID == current,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"items": out,
+		"total": len(out),
+	})
+}
+
+// Revoke signs out one of the admin's sessions (protected)
+func (h *SessionsHandler) Revoke(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid ID",
+		})
+	}
+
+	var session models.AdminSession
+	if err := database.DB.
+		Where("id = ? AND admin_user_id = ?", id, currentAdminID(c)).
+		First(&session).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Session not found",
+		})
+	}
+
+	if err := h.sessions.Revoke(session.ID, models.SessionRevokedByUser); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to revoke session",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOthers signs out every session except the current one (protected)
+func (h *SessionsHandler) RevokeOthers(c *fiber.Ctx) error {
+	if err := database.DB.Model(&models.AdminSession{}).
+		Where("admin_user_id = ? AND id <> ? AND revoked_at IS NULL", currentAdminID(c), currentSessionID(c)).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": models.SessionRevokedByUser,
+		}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to revoke sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Other sessions revoked",
+	})
+}
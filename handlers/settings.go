@@ -3,6 +3,8 @@ package handlers
 import (
 	"eman-backend/database"
 	"eman-backend/models"
+	"eman-backend/services"
+	"log"
 	"sync"
 	"time"
 
@@ -14,17 +16,34 @@ type SettingsHandler struct {
 	cacheMu   sync.RWMutex
 	cacheTime time.Time
 	cacheTTL  time.Duration
+	backplane services.Backplane
+	content   *services.ContentEventService
+	media     *services.MediaService
 }
 
-func NewSettingsHandler() *SettingsHandler {
-	return &SettingsHandler{
-		cache:    make(map[string][]models.SiteSetting),
-		cacheTTL: 5 * time.Minute, // Cache for 5 minutes
+func NewSettingsHandler(backplane services.Backplane, content *services.ContentEventService, media *services.MediaService) *SettingsHandler {
+	h := &SettingsHandler{
+		cache:     make(map[string][]models.SiteSetting),
+		cacheTTL:  5 * time.Minute, // Cache for 5 minutes
+		backplane: backplane,
+		content:   content,
+		media:     media,
 	}
+	// Other instances announce their edits so no replica serves stale settings
+	backplane.Subscribe(services.BackplaneChannelSettings, func(uint64, []byte) { h.dropCache() })
+	return h
 }
 
-// clearCache invalidates the cache
+// clearCache invalidates the cache on every instance
 func (h *SettingsHandler) clearCache() {
+	h.dropCache()
+	if err := h.backplane.Publish(services.BackplaneChannelSettings, nil); err != nil {
+		log.Printf("[Settings] failed to invalidate cache on other instances: %v", err)
+	}
+}
+
+// dropCache invalidates this instance's cache
+func (h *SettingsHandler) dropCache() {
 	h.cacheMu.Lock()
 	defer h.cacheMu.Unlock()
 	h.cache = make(map[string][]models.SiteSetting)
@@ -156,6 +175,9 @@ func (h *SettingsHandler) Update(c *fiber.Ctx) error {
 	// Clear cache
 	h.clearCache()
 
+	h.media.Track(services.ContentSetting, setting.Key, services.MediaFields{"value": setting.Value})
+	h.content.Emit(services.ContentSetting, services.ContentUpdated, setting.Key)
+
 	return c.JSON(setting)
 }
 
@@ -197,6 +219,15 @@ func (h *SettingsHandler) BulkUpdate(c *fiber.Ctx) error {
 	// Clear cache
 	h.clearCache()
 
+	keys := make([]string, 0, len(req.Settings))
+	for _, item := range req.Settings {
+		keys = append(keys, item.Key)
+	}
+	for _, item := range req.Settings {
+		h.media.Track(services.ContentSetting, item.Key, services.MediaFields{"value": item.Value})
+	}
+	h.content.Emit(services.ContentSetting, services.ContentUpdated, keys...)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Settings updated",
@@ -228,6 +259,12 @@ func (h *SettingsHandler) Seed(c *fiber.Ctx) error {
 	// Clear cache
 	h.clearCache()
 
+	h.media.ReleaseType(services.ContentSetting)
+	for _, setting := range defaults {
+		h.media.Track(services.ContentSetting, setting.Key, services.MediaFields{"value": setting.Value})
+	}
+	h.content.Emit(services.ContentSetting, services.ContentReset)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Settings reset to defaults",
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxShortlistItems = 20
+	maxCompareItems   = 10
+)
+
+type ShortlistsHandler struct {
+	macroService *services.MacroService
+}
+
+func NewShortlistsHandler(macroService *services.MacroService) *ShortlistsHandler {
+	return &ShortlistsHandler{macroService: macroService}
+}
+
+func findShortlist(token string) (*models.Shortlist, error) {
+	var shortlist models.Shortlist
+	err := database.DB.
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Where("token = ?", strings.TrimSpace(token)).
+		First(&shortlist).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shortlist, nil
+}
+
+func shortlistEstateIDs(shortlist *models.Shortlist) []int {
+	ids := make([]int, 0, len(shortlist.Items))
+	for _, item := range shortlist.Items {
+		ids = append(ids, item.EstateID)
+	}
+	return ids
+}
+
+// shortlistResponse pairs the shortlist with the cached estate records.
+func (h *ShortlistsHandler) shortlistResponse(shortlist *models.Shortlist) fiber.Map {
+	estates := make([]map[string]any, 0, len(shortlist.Items))
+	for _, item := range shortlist.Items {
+		if estate := h.macroService.GetCachedEstateByID(item.EstateID); estate != nil {
+			estates = append(estates, estate)
+		}
+	}
+
+	return fiber.Map{
+		"token":      shortlist.Token,
+		"estate_ids": shortlistEstateIDs(shortlist),
+		"estates":    estates,
+		"created_at": shortlist.CreatedAt,
+		"updated_at": shortlist.UpdatedAt,
+	}
+}
+
+// Create starts a new anonymous shortlist (public endpoint)
+func (h *ShortlistsHandler) Create(c *fiber.Ctx) error {
+	shortlist := models.Shortlist{
+		Token:     strings.ReplaceAll(uuid.New().String(), "-", ""),
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+
+	if err := database.DB.Create(&shortlist).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to create shortlist",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(h.shortlistResponse(&shortlist))
+}
+
+// Get returns a shortlist by share token (public endpoint)
+func (h *ShortlistsHandler) Get(c *fiber.Ctx) error {
+	shortlist, err := findShortlist(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Shortlist not found",
+		})
+	}
+
+	return c.JSON(h.shortlistResponse(shortlist))
+}
+
+type AddShortlistItemRequest struct {
+	EstateID int `json:"estate_id"`
+}
+
+// AddItem puts an estate on the shortlist (public endpoint)
+func (h *ShortlistsHandler) AddItem(c *fiber.Ctx) error {
+	shortlist, err := findShortlist(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Shortlist not found",
+		})
+	}
+
+	var req AddShortlistItemRequest
+	if err := c.BodyParser(&req); err != nil || req.EstateID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "estate_id is required",
+		})
+	}
+
+	if h.macroService.GetCachedEstateByID(req.EstateID) == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Estate not found",
+		})
+	}
+
+	if len(shortlist.Items) >= maxShortlistItems {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Shortlist is full, max " + strconv.Itoa(maxShortlistItems) + " estates",
+		})
+	}
+
+	item := models.ShortlistItem{ShortlistID: shortlist.ID, EstateID: req.EstateID}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&item).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update shortlist",
+		})
+	}
+	database.DB.Model(shortlist).Update("updated_at", time.Now())
+
+	shortlist, _ = findShortlist(shortlist.Token)
+	return c.JSON(h.shortlistResponse(shortlist))
+}
+
+// RemoveItem takes an estate off the shortlist (public endpoint)
+func (h *ShortlistsHandler) RemoveItem(c *fiber.Ctx) error {
+	shortlist, err := findShortlist(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Shortlist not found",
+		})
+	}
+
+	estateID, err := strconv.Atoi(c.Params("estateId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid estate ID",
+		})
+	}
+
+	if err := database.DB.
+		Where("shortlist_id = ? AND estate_id = ?", shortlist.ID, estateID).
+		Delete(&models.ShortlistItem{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update shortlist",
+		})
+	}
+
+	shortlist, _ = findShortlist(shortlist.Token)
+	return c.JSON(h.shortlistResponse(shortlist))
+}
+
+// Compare returns the shortlisted estates side by side (public endpoint).
+// ?ids=1,2,3 narrows the comparison to a subset of the shortlist.
+func (h *ShortlistsHandler) Compare(c *fiber.Ctx) error {
+	shortlist, err := findShortlist(c.Params("token"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"message": "Shortlist not found",
+		})
+	}
+
+	ids := shortlistEstateIDs(shortlist)
+	if raw := strings.TrimSpace(c.Query("ids")); raw != "" {
+		onList := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			onList[id] = true
+		}
+		ids = ids[:0]
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err == nil && onList[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > maxCompareItems {
+		ids = ids[:maxCompareItems]
+	}
+
+	estates := make([]map[string]any, 0, len(ids))
+	missing := []int{}
+	for _, id := range ids {
+		if estate := h.macroService.GetCachedEstateByID(id); estate != nil {
+			estates = append(estates, estate)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"estates": estates,
+		"fields":  services.CompareEstates(estates),
+		"missing": missing,
+	})
+}
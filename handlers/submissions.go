@@ -71,6 +71,11 @@ func (h *SubmissionsHandler) List(c *fiber.Ctx) error {
 		query = query.Where("source = ?", source)
 	}
 
+	// Incremental pull for integrations
+	if sinceID := c.QueryInt("since_id", 0); sinceID > 0 {
+		query = query.Where("id > ?", sinceID)
+	}
+
 	// Pagination
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
@@ -123,20 +128,18 @@ type CreateSubmissionRequest struct {
 	Source      string `json:"source"`
 	EstateID    *int   `json:"estate_id"`
 	PaymentPlan string `json:"payment_plan"`
-}
 
-type paymentPlanSetting struct {
-	Title string `json:"title"`
+	// Calculation is the calculator selection the visitor saw; it is
+	// recomputed server-side before being attached to the submission.
+	Calculation *PaymentCalculationRequest `json:"calculation"`
+
+	// ShortlistToken attaches the visitor's favourites to the submission.
+	ShortlistToken string `json:"shortlist_token"`
 }
 
 func (h *SubmissionsHandler) defaultPaymentPlan() string {
-	var setting models.SiteSetting
-	if err := database.DB.Where("key = ?", "payment_plans").First(&setting).Error; err != nil {
-		return ""
-	}
-
-	var plans []paymentPlanSetting
-	if err := json.Unmarshal([]byte(setting.Value), &plans); err != nil {
+	plans, err := loadPaymentPlans("")
+	if err != nil {
 		return ""
 	}
 
@@ -217,17 +220,56 @@ func (h *SubmissionsHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
+	var calculation *services.PaymentCalculation
+	var calculationJSON string
+	if req.Calculation != nil {
+		if req.Calculation.EstateID == nil {
+			req.Calculation.EstateID = req.EstateID
+		}
+		// A calculator problem must not cost the lead; it is saved without one
+		var err error
+		calculation, err = calculatePayment(h.macroService, *req.Calculation)
+		if err != nil {
+			log.Printf("[Submissions] payment calculation skipped: %v", err)
+			calculation = nil
+		}
+	}
+	if calculation != nil {
+		encoded, _ := json.Marshal(calculation)
+		calculationJSON = string(encoded)
+		if calculation.Plan != "" {
+			req.PaymentPlan = calculation.Plan
+		}
+	}
+
+	var shortlistID *uint
+	var shortlistIDs []int
+	if token := strings.TrimSpace(req.ShortlistToken); token != "" {
+		if shortlist, err := findShortlist(token); err == nil {
+			shortlistID = &shortlist.ID
+			shortlistIDs = shortlistEstateIDs(shortlist)
+		}
+	}
+	shortlistJSON := ""
+	if shortlistID != nil {
+		encoded, _ := json.Marshal(shortlistIDs)
+		shortlistJSON = string(encoded)
+	}
+
 	submission := models.ContactSubmission{
-		Name:        req.Name,
-		Phone:       req.Phone,
-		Email:       req.Email,
-		Message:     req.Message,
-		Source:      req.Source,
-		EstateID:    req.EstateID,
-		PaymentPlan: req.PaymentPlan,
-		Status:      "new",
-		IPAddress:   c.IP(),
-		UserAgent:   c.Get("User-Agent"),
+		Name:               req.Name,
+		Phone:              req.Phone,
+		Email:              req.Email,
+		Message:            req.Message,
+		Source:             req.Source,
+		EstateID:           req.EstateID,
+		PaymentPlan:        req.PaymentPlan,
+		PaymentCalculation: calculationJSON,
+		ShortlistID:        shortlistID,
+		ShortlistEstateIDs: shortlistJSON,
+		Status:             "new",
+		IPAddress:          c.IP(),
+		UserAgent:          c.Get("User-Agent"),
 	}
 
 	if err := database.DB.Create(&submission).Error; err != nil {
@@ -266,8 +308,28 @@ func (h *SubmissionsHandler) Create(c *fiber.Ctx) error {
 			}
 		}
 
+		calculationLine := "-"
+		if calculation != nil {
+			calculationLine = fmt.Sprintf(
+				"взнос %.2f (%s%%), %d мес. по %.2f",
+				calculation.DownPayment,
+				strconv.FormatFloat(calculation.DownPaymentPercent, 'f', -1, 64),
+				calculation.TermMonths,
+				calculation.MonthlyPayment,
+			)
+		}
+
+		shortlistLine := "-"
+		if len(shortlistIDs) > 0 {
+			parts := make([]string, 0, len(shortlistIDs))
+			for _, id := range shortlistIDs {
+				parts = append(parts, strconv.Itoa(id))
+			}
+			shortlistLine = strings.Join(parts, ", ")
+		}
+
 		text := fmt.Sprintf(
-			"🔔 Новая заявка\nID: %d\nИсточник: %s\nИмя: %s\nТелефон: %s\nID объекта: %s\nОбъект: %s\nПлан оплаты: %s\nСообщение: %s",
+			"🔔 Новая заявка\nID: %d\nИсточник: %s\nИмя: %s\nТелефон: %s\nID объекта: %s\nОбъект: %s\nПлан оплаты: %s\nРасчёт: %s\nИзбранное: %s\nСообщение: %s",
 			submission.ID,
 			safeLine(sourceRu(req.Source)),
 			safeLine(req.Name),
@@ -275,6 +337,8 @@ func (h *SubmissionsHandler) Create(c *fiber.Ctx) error {
 			estateID,
 			estateDetails,
 			safeLine(req.PaymentPlan),
+			calculationLine,
+			shortlistLine,
 			safeLine(req.Message),
 		)
 
@@ -285,14 +349,15 @@ func (h *SubmissionsHandler) Create(c *fiber.Ctx) error {
 		log.Printf("[Telegram] Notification sent for submission #%d", submission.ID)
 	}()
 
-	// Broadcast new submission to all connected admin clients
-	h.hub.Broadcast("new_submission", fiber.Map{
+	// Notify admins subscribed to leads; contact details never reach public sockets
+	h.hub.Publish(ws.TopicAdminSubmissions, "new_submission", fiber.Map{
 		"id":           submission.ID,
 		"name":         submission.Name,
 		"phone":        submission.Phone,
 		"source":       submission.Source,
 		"estate_id":    submission.EstateID,
 		"payment_plan": submission.PaymentPlan,
+		"shortlist":    shortlistIDs,
 		"message":      submission.Message,
 		"createdAt":    submission.CreatedAt,
 	})
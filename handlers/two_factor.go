@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Intermediate tokens handed out by Login when a second factor is needed.
+// They are signed like access tokens but AuthRequired rejects their issuer.
+const (
+	twoFactorVerifyIssuer = "eman-backend-2fa"
+	twoFactorSetupIssuer  = "eman-backend-2fa-setup"
+	twoFactorTokenTTL     = 5 * time.Minute
+)
+
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// parseTwoFactorToken returns the admin an intermediate token was issued to.
+func (h *AuthHandler) parseTwoFactorToken(tokenString, issuer string) (*models.AdminUser, error) {
+	claims, err := h.keys.Parse(tokenString)
+	if err != nil {
+		return nil, errors.New("invalid or expired two-factor token")
+	}
+	if claims.Issuer != issuer {
+		return nil, errors.New("invalid two-factor token")
+	}
+
+	var admin models.AdminUser
+	if err := database.DB.Where("username = ?", claims.Username).First(&admin).Error; err != nil || admin.Disabled {
+		return nil, errors.New("account is disabled or no longer exists")
+	}
+	return &admin, nil
+}
+
+// VerifyTwoFactor completes a login with a TOTP or recovery code
+func (h *AuthHandler) VerifyTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	admin, err := h.parseTwoFactorToken(req.TwoFactorToken, twoFactorVerifyIssuer)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor session expired, please sign in again",
+		})
+	}
+
+	ip := c.IP()
+	userAgent := c.Get("User-Agent")
+	if blocked, err := h.rejectThrottled(c, admin.Username, ip, userAgent); blocked {
+		return err
+	}
+
+	if strings.TrimSpace(req.RecoveryCode) != "" {
+		err = h.twoFactor.UseRecoveryCode(admin, req.RecoveryCode)
+	} else {
+		err = h.twoFactor.Verify(admin, req.Code)
+	}
+	if err != nil {
+		h.loginGuard.RecordFailure(admin.Username, ip, userAgent, models.LoginReasonInvalid2FA)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid two-factor code",
+		})
+	}
+
+	h.loginGuard.RecordSuccess(admin.Username, ip, userAgent)
+	return h.completeLogin(c, admin, nil)
+}
+
+// BeginTwoFactorSetup starts the enrollment forced by the owner policy
+func (h *AuthHandler) BeginTwoFactorSetup(c *fiber.Ctx) error {
+	var req TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	admin, err := h.parseTwoFactorToken(req.TwoFactorToken, twoFactorSetupIssuer)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor session expired, please sign in again",
+		})
+	}
+	// Enrolled after the setup token was issued: the token is spent
+	if admin.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, uri, err := h.twoFactor.BeginEnrollment(admin)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to start two-factor setup",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTwoFactorSetup finishes forced enrollment and signs the admin in
+func (h *AuthHandler) ConfirmTwoFactorSetup(c *fiber.Ctx) error {
+	var req TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	admin, err := h.parseTwoFactorToken(req.TwoFactorToken, twoFactorSetupIssuer)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor session expired, please sign in again",
+		})
+	}
+	if admin.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	ip := c.IP()
+	userAgent := c.Get("User-Agent")
+	if blocked, err := h.rejectThrottled(c, admin.Username, ip, userAgent); blocked {
+		return err
+	}
+
+	codes, err := h.twoFactor.ConfirmEnrollment(admin, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrTwoFactorInvalid) {
+			h.loginGuard.RecordFailure(admin.Username, ip, userAgent, models.LoginReasonInvalid2FA)
+		}
+		return twoFactorError(c, err)
+	}
+
+	h.loginGuard.RecordSuccess(admin.Username, ip, userAgent)
+	return h.completeLogin(c, admin, codes)
+}
+
+func twoFactorError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTwoFactorInvalid):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid two-factor code",
+		})
+	case errors.Is(err, services.ErrTwoFactorNotPending):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor setup has not been started",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update two-factor settings",
+		})
+	}
+}
+
+// TwoFactorHandler is the signed-in admin's own 2FA management plus the owner policy.
+type TwoFactorHandler struct {
+	twoFactor *services.TwoFactorService
+	sessions  *services.SessionService
+}
+
+func NewTwoFactorHandler(twoFactor *services.TwoFactorService, sessions *services.SessionService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactor: twoFactor, sessions: sessions}
+}
+
+func currentAdmin(c *fiber.Ctx) (*models.AdminUser, error) {
+	var admin models.AdminUser
+	if err := database.DB.First(&admin, currentAdminID(c)).Error; err != nil {
+		return nil, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Not authenticated",
+		})
+	}
+	return &admin, nil
+}
+
+// Status returns the admin's 2FA state (protected)
+func (h *TwoFactorHandler) Status(c *fiber.Ctx) error {
+	admin, err := currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"enabled":             admin.TOTPEnabled,
+		"enabled_at":          admin.TOTPEnabledAt,
+		"pending":             admin.TOTPPendingSecret != "",
+		"required":            h.twoFactor.Required(),
+		"recovery_codes_left": h.twoFactor.RecoveryCodesLeft(admin.ID),
+	})
+}
+
+// Setup generates a new secret and provisioning URI for the QR code (protected)
+func (h *TwoFactorHandler) Setup(c *fiber.Ctx) error {
+	admin, err := currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+	if admin.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, uri, err := h.twoFactor.BeginEnrollment(admin)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to start two-factor setup",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	Password     string `json:"password"`
+}
+
+// Confirm enables 2FA once the first code checks out (protected)
+func (h *TwoFactorHandler) Confirm(c *fiber.Ctx) error {
+	admin, err := currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	codes, err := h.twoFactor.ConfirmEnrollment(admin, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns 2FA off; needs the password and a current code (protected)
+func (h *TwoFactorHandler) Disable(c *fiber.Ctx) error {
+	admin, err := currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	if h.twoFactor.Required() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   true,
+			"message": "Two-factor authentication is required for all admins",
+		})
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Current password is incorrect",
+		})
+	}
+	if err := h.checkCode(admin, req); err != nil {
+		return twoFactorError(c, err)
+	}
+
+	if err := h.twoFactor.Disable(admin.ID); err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes (protected)
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	admin, err := currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+	if err := h.checkCode(admin, req); err != nil {
+		return twoFactorError(c, err)
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(admin.ID)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// checkCode accepts either a TOTP code or a recovery code.
+func (h *TwoFactorHandler) checkCode(admin *models.AdminUser, req TwoFactorCodeRequest) error {
+	if !admin.TOTPEnabled {
+		return services.ErrTwoFactorInvalid
+	}
+	if strings.TrimSpace(req.RecoveryCode) != "" {
+		return h.twoFactor.UseRecoveryCode(admin, req.RecoveryCode)
+	}
+	return h.twoFactor.Verify(admin, req.Code)
+}
+
+// Policy returns the owner security policy (owner)
+func (h *TwoFactorHandler) Policy(c *fiber.Ctx) error {
+	return c.JSON(h.twoFactor.Policy())
+}
+
+type UpdateSecurityPolicyRequest struct {
+	Require2FA bool `json:"require_2fa"`
+}
+
+// UpdatePolicy switches mandatory 2FA on or off (owner)
+func (h *TwoFactorHandler) UpdatePolicy(c *fiber.Ctx) error {
+	var req UpdateSecurityPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid request body",
+		})
+	}
+
+	policy, err := h.twoFactor.SetRequired(req.Require2FA, currentUsername(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   true,
+			"message": "Failed to update policy",
+		})
+	}
+
+	return c.JSON(policy)
+}
+
+// ResetForUser removes another admin's 2FA after a lost device (owner)
+func (h *TwoFactorHandler) ResetForUser(c *fiber.Ctx) error {
+	user, err := findAdminUser(c)
+	if user == nil {
+		return err
+	}
+
+	if err := h.twoFactor.Disable(user.ID); err != nil {
+		return twoFactorError(c, err)
+	}
+	h.sessions.RevokeAll(user.ID, models.SessionRevokedByUser)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Two-factor authentication reset",
+	})
+}
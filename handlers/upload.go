@@ -9,15 +9,16 @@ import (
 
 type UploadHandler struct {
 	storage *services.StorageService
+	media   *services.MediaService
 }
 
-func NewUploadHandler(storage *services.StorageService) *UploadHandler {
-	return &UploadHandler{storage: storage}
+func NewUploadHandler(storage *services.StorageService, media *services.MediaService) *UploadHandler {
+	return &UploadHandler{storage: storage, media: media}
 }
 
 // Upload handles single file upload
 func (h *UploadHandler) Upload(c *fiber.Ctx) error {
-	relativePath, err := uploadFromRequest(c, h.storage)
+	stored, err := uploadFromRequest(c, h.storage, h.media)
 	if err != nil {
 		message := err.Error()
 		if errors.Is(err, errNoFileUploaded) {
@@ -30,11 +31,18 @@ func (h *UploadHandler) Upload(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"url":     "/uploads/" + relativePath,
-		"path":    relativePath,
-	})
+	response := uploadedFile(h.storage, stored)
+	response["success"] = true
+	return c.JSON(response)
+}
+
+// Redirect sends /uploads/* requests to the file's storage URL
+func (h *UploadHandler) Redirect(c *fiber.Ctx) error {
+	key := c.Params("*")
+	if key == "" || services.IsReservedKey(key) {
+		return fiber.ErrNotFound
+	}
+	return c.Redirect(h.storage.URL(key), fiber.StatusMovedPermanently)
 }
 
 // UploadMultiple handles multiple files upload
@@ -57,8 +65,9 @@ func (h *UploadHandler) UploadMultiple(c *fiber.Ctx) error {
 
 	var uploaded []fiber.Map
 	for _, file := range files {
-		relativePath, err := h.storage.UploadFile(file)
+		stored, err := h.storage.UploadFile(file)
 		if err != nil {
+			recordQuarantined(c, h.media, err)
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error":   true,
 				"message": err.Error(),
@@ -66,11 +75,11 @@ func (h *UploadHandler) UploadMultiple(c *fiber.Ctx) error {
 			})
 		}
 
-		uploaded = append(uploaded, fiber.Map{
-			"url":           "/uploads/" + relativePath,
-			"path":          relativePath,
-			"original_name": file.Filename,
-		})
+		recordUpload(c, h.media, stored)
+
+		item := uploadedFile(h.storage, stored)
+		item["original_name"] = file.Filename
+		uploaded = append(uploaded, item)
 	}
 
 	return c.JSON(fiber.Map{
@@ -3,7 +3,9 @@ package handlers
 import (
 	"bytes"
 	"errors"
+	"log"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"eman-backend/services"
@@ -13,13 +15,73 @@ import (
 
 var errNoFileUploaded = errors.New("no file uploaded")
 
-func uploadFromRequest(c *fiber.Ctx, storage *services.StorageService) (string, error) {
+// uploadFromRequest stores the request's file and adds it to the media library.
+func uploadFromRequest(c *fiber.Ctx, storage *services.StorageService, media *services.MediaService) (*services.StoredFile, error) {
+	stored, err := storeFromRequest(c, storage)
+	if err != nil {
+		recordQuarantined(c, media, err)
+		return nil, err
+	}
+	recordUpload(c, media, stored)
+	return stored, nil
+}
+
+// uploadedFile describes a stored file for upload responses. Images with
+// variants also get a srcset covering the variants and the original.
+func uploadedFile(storage *services.StorageService, stored *services.StoredFile) fiber.Map {
+	result := fiber.Map{
+		"url":  storage.URL(stored.Path),
+		"path": stored.Path,
+	}
+	if stored.Width > 0 {
+		result["width"] = stored.Width
+		result["height"] = stored.Height
+	}
+
+	variants := make([]fiber.Map, 0, len(stored.Variants))
+	srcset := make([]string, 0, len(stored.Variants)+1)
+	for _, variant := range stored.Variants {
+		url := storage.URL(variant.Path)
+		variants = append(variants, fiber.Map{
+			"width":  variant.Width,
+			"height": variant.Height,
+			"url":    url,
+			"path":   variant.Path,
+		})
+		srcset = append(srcset, url+" "+strconv.Itoa(variant.Width)+"w")
+	}
+	result["variants"] = variants
+	if len(srcset) > 0 {
+		srcset = append(srcset, storage.URL(stored.Path)+" "+strconv.Itoa(stored.Width)+"w")
+		result["srcset"] = strings.Join(srcset, ", ")
+	}
+	return result
+}
+
+// recordUpload adds a stored file to the media library. The upload itself
+// has succeeded either way; an unrecorded file is simply never collected.
+func recordUpload(c *fiber.Ctx, media *services.MediaService, stored *services.StoredFile) {
+	if _, err := media.Record(stored, currentUsername(c)); err != nil {
+		log.Printf("[Media] failed to record upload %s: %v", stored.Path, err)
+	}
+}
+
+// recordQuarantined lists an upload the scanner rejected in the media
+// library, so admins can review it.
+func recordQuarantined(c *fiber.Ctx, media *services.MediaService, err error) {
+	var infected *services.InfectedError
+	if errors.As(err, &infected) {
+		recordUpload(c, media, infected.File)
+	}
+}
+
+func storeFromRequest(c *fiber.Ctx, storage *services.StorageService) (*services.StoredFile, error) {
 	contentType := c.Get("Content-Type")
 
 	if strings.HasPrefix(contentType, "multipart/form-data") {
 		file, err := c.FormFile("file")
 		if err != nil {
-			return "", errNoFileUploaded
+			return nil, errNoFileUploaded
 		}
 		return storage.UploadFile(file)
 	}
@@ -29,7 +91,7 @@ func uploadFromRequest(c *fiber.Ctx, storage *services.StorageService) (string,
 		filename = c.Query("filename")
 	}
 	if filename == "" {
-		return "", errors.New("missing X-Filename header")
+		return nil, errors.New("missing X-Filename header")
 	}
 	if decoded, err := url.QueryUnescape(filename); err == nil {
 		filename = decoded
@@ -40,7 +102,7 @@ func uploadFromRequest(c *fiber.Ctx, storage *services.StorageService) (string,
 	if body == nil {
 		raw := c.Body()
 		if len(raw) == 0 {
-			return "", errors.New("request body is empty")
+			return nil, errors.New("request body is empty")
 		}
 		body = bytes.NewReader(raw)
 		if contentLength <= 0 {
@@ -49,7 +111,7 @@ func uploadFromRequest(c *fiber.Ctx, storage *services.StorageService) (string,
 	}
 
 	if contentLength <= 0 {
-		return "", errors.New("content length required")
+		return nil, errors.New("content length required")
 	}
 
 	return storage.UploadStream(filename, contentType, contentLength, body)
@@ -1,90 +1,108 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	"eman-backend/middleware"
+	"eman-backend/services"
 	ws "eman-backend/websocket"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 )
 
-const (
-	// Time allowed to write a message to the peer
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period (must be less than pongWait)
-	pingPeriod = (pongWait * 9) / 10
-)
-
 type WebSocketHandler struct {
-	hub *ws.Hub
+	hub  *ws.Hub
+	keys *services.JWTKeySet
 }
 
-func NewWebSocketHandler() *WebSocketHandler {
+func NewWebSocketHandler(keys *services.JWTKeySet) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub: ws.GetHub(),
+		hub:  ws.GetHub(),
+		keys: keys,
 	}
 }
 
-// Upgrade checks if the request is a WebSocket upgrade request
+// clientMessage is a control message sent by the client.
+type clientMessage struct {
+	Type    string   `json:"type"` // auth, subscribe, unsubscribe, resume
+	Token   string   `json:"token"`
+	Topics  []string `json:"topics"`
+	Epoch   string   `json:"epoch"`
+	LastSeq uint64   `json:"last_seq"`
+}
+
+// splitTopics parses a comma-separated topics query parameter.
+func splitTopics(value string) []string {
+	var topics []string
+	for _, topic := range strings.Split(value, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// Upgrade checks if the request is a WebSocket upgrade request. A token in
+// the query string is verified here so bad credentials fail the handshake.
 func (h *WebSocketHandler) Upgrade(c *fiber.Ctx) error {
-	if websocket.IsWebSocketUpgrade(c) {
-		c.Locals("allowed", true)
-		return c.Next()
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	if token := c.Query("token"); token != "" {
+		identity, msg := middleware.VerifyAccessToken(h.keys, token)
+		if identity == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": msg,
+			})
+		}
+		c.Locals("ws_identity", identity)
 	}
-	return fiber.ErrUpgradeRequired
+
+	c.Locals("allowed", true)
+	return c.Next()
 }
 
 // Handle manages WebSocket connections
 func (h *WebSocketHandler) Handle(c *websocket.Conn) {
-	client := &ws.Client{
-		Conn: c,
-		Hub:  h.hub,
+	client := ws.NewClient(c, h.hub)
+
+	if identity, ok := c.Locals("ws_identity").(*middleware.Identity); ok {
+		client.Authenticate(identity.Username, identity.Role, identity.ExpiresAt)
+	}
+	if topics := splitTopics(c.Query("topics")); len(topics) > 0 {
+		h.subscribe(client, topics)
 	}
 
+	// The hub starts the client's writer, which also sends the keepalive pings
 	h.hub.Register(client)
+	defer func() {
+		h.hub.Unregister(client)
+		client.Wait()
+	}()
 
-	// Start ping ticker for keepalive
-	ticker := time.NewTicker(pingPeriod)
-	done := make(chan struct{})
-
-	// Goroutine for sending pings
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				c.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
-					log.Printf("Ping error: %v", err)
-					return
-				}
-			case <-done:
-				return
-			}
+	// Reconnecting clients pass the last sequence number they saw
+	if lastSeq := c.Query("last_seq"); lastSeq != "" {
+		if seq, err := strconv.ParseUint(lastSeq, 10, 64); err == nil {
+			h.hub.Resume(client, c.Query("epoch"), seq)
 		}
-	}()
+	}
 
 	// Set initial read deadline
-	c.SetReadDeadline(time.Now().Add(pongWait))
+	c.SetReadDeadline(time.Now().Add(ws.PongWait))
 
 	// Handle pong messages to reset read deadline
 	c.SetPongHandler(func(string) error {
-		c.SetReadDeadline(time.Now().Add(pongWait))
+		c.SetReadDeadline(time.Now().Add(ws.PongWait))
 		return nil
 	})
 
-	// Keep connection alive and handle incoming messages
-	defer func() {
-		close(done)
-		h.hub.Unregister(client)
-	}()
-
 	for {
 		messageType, msg, err := c.ReadMessage()
 		if err != nil {
@@ -94,19 +112,66 @@ func (h *WebSocketHandler) Handle(c *websocket.Conn) {
 			break
 		}
 
-		// Handle text messages (like heartbeat from client)
-		if messageType == websocket.TextMessage {
-			// Client sent a heartbeat or message
-			if string(msg) == "ping" {
-				c.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := c.WriteMessage(websocket.TextMessage, []byte("pong")); err != nil {
-					break
-				}
-			}
+		if messageType != websocket.TextMessage {
+			continue
 		}
+
+		// Plain-text heartbeat from older clients
+		if string(msg) == "ping" {
+			client.SendRaw([]byte("pong"))
+			continue
+		}
+
+		var req clientMessage
+		if err := json.Unmarshal(msg, &req); err != nil {
+			client.Send("error", fiber.Map{"message": "Invalid message"})
+			continue
+		}
+		h.handleMessage(client, req)
 	}
 }
 
+func (h *WebSocketHandler) handleMessage(client *ws.Client, req clientMessage) {
+	switch req.Type {
+	case "auth":
+		identity, msg := middleware.VerifyAccessToken(h.keys, req.Token)
+		if identity == nil {
+			client.Send("auth_error", fiber.Map{"message": msg})
+			return
+		}
+		client.Authenticate(identity.Username, identity.Role, identity.ExpiresAt)
+		client.Send("auth_ok", fiber.Map{
+			"username":   identity.Username,
+			"role":       identity.Role,
+			"expires_at": identity.ExpiresAt.Unix(),
+		})
+		if len(req.Topics) > 0 {
+			h.subscribe(client, req.Topics)
+		}
+
+	case "subscribe":
+		h.subscribe(client, req.Topics)
+
+	case "resume":
+		h.hub.Resume(client, req.Epoch, req.LastSeq)
+
+	case "unsubscribe":
+		client.Unsubscribe(req.Topics)
+		client.Send("unsubscribed", fiber.Map{"topics": req.Topics})
+
+	default:
+		client.Send("error", fiber.Map{"message": "Unknown message type"})
+	}
+}
+
+func (h *WebSocketHandler) subscribe(client *ws.Client, topics []string) {
+	subscribed, denied := client.Subscribe(topics)
+	client.Send("subscribed", fiber.Map{
+		"topics": subscribed,
+		"denied": denied,
+	})
+}
+
 // GetHub returns the WebSocket hub for broadcasting
 func (h *WebSocketHandler) GetHub() *ws.Hub {
 	return h.hub
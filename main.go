@@ -1,12 +1,14 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 
 	"eman-backend/config"
 	"eman-backend/database"
 	"eman-backend/routes"
+	"eman-backend/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -21,6 +23,14 @@ func main() {
 	}
 
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		migrateStorage(cfg, os.Args[2:])
+		return
+	}
 
 	// Connect to database
 	if err := database.Connect(cfg.DBDSN); err != nil {
@@ -37,21 +47,31 @@ func main() {
 	}
 
 	// Create upload directory
-	if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
-		log.Fatalf("Failed to create upload directory: %v", err)
+	if cfg.StorageBackend == "local" {
+		if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
+			log.Fatalf("Failed to create upload directory: %v", err)
+		}
 	}
 
 	app := fiber.New(fiber.Config{
 		AppName:           "Eman Backend API",
 		BodyLimit:         cfg.MaxUploadSizeMB * 1024 * 1024,
 		StreamRequestBody: true,
+
+		// Behind nginx every request comes from the proxy; login throttling
+		// and audit entries need the real client address
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableIPValidation:      true,
 	})
 
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000,http://127.0.0.1:3000,http://95.46.96.115:3000,https://emandevelopment.uz",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Filename",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Filename,Last-Event-ID,Upload-Length,Upload-Offset,Upload-Metadata,Upload-Checksum,Tus-Resumable",
+		ExposeHeaders:    "Location,Upload-Offset,Upload-Length,Upload-Expires,Upload-Result-Path,Upload-Result-URL,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Tus-Checksum-Algorithm",
 		AllowCredentials: true,
 	}))
 
@@ -60,3 +80,35 @@ func main() {
 	log.Printf("Server starting on port %s", cfg.Port)
 	log.Fatal(app.Listen(":" + cfg.Port))
 }
+
+// migrateStorage copies uploaded files between storage backends:
+//
+//	eman-backend migrate-storage -from local -to s3 [-overwrite]
+//
+// Both backends are configured from the environment as usual.
+func migrateStorage(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	from := flags.String("from", "local", "source backend: local or s3")
+	to := flags.String("to", "s3", "destination backend: local or s3")
+	overwrite := flags.Bool("overwrite", false, "replace files that already exist in the destination")
+	flags.Parse(args)
+
+	if *from == *to {
+		log.Fatalf("Source and destination are both %s", *from)
+	}
+
+	src, err := services.NewStorageBackend(cfg, *from)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", *from, err)
+	}
+	dst, err := services.NewStorageBackend(cfg, *to)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", *to, err)
+	}
+
+	copied, skipped, err := services.CopyStorage(src, dst, *overwrite)
+	if err != nil {
+		log.Fatalf("Storage migration stopped after %d files: %v", copied, err)
+	}
+	log.Printf("Storage migration done: %d copied, %d already present", copied, skipped)
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Last-used tracking is written at most this often per key.
+const apiKeyTouchInterval = time.Minute
+
+func authenticateAPIKey(c *fiber.Ctx, raw string) error {
+	var key models.APIKey
+	if err := database.DB.Where("key_hash = ?", models.HashAPIKey(raw)).First(&key).Error; err != nil || !key.Active() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   true,
+			"message": "Invalid, expired or revoked API key",
+		})
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval || key.LastUsedIP != c.IP() {
+		database.DB.Model(&key).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.IP(),
+		})
+	}
+
+	c.Locals("username", "api-key:"+key.Name)
+	c.Locals("api_key", &key)
+	c.Locals("api_key_id", key.ID)
+
+	return c.Next()
+}
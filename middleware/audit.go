@@ -0,0 +1,311 @@
+package middleware
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// auditEntity maps an /api/admin/<resource> path segment to the model it edits.
+type auditEntity struct {
+	Type      string
+	KeyColumn string // column the path segment after the resource refers to
+	New       func() any
+}
+
+var auditEntities = map[string]auditEntity{
+	"gallery":        {Type: "gallery_item", KeyColumn: "id", New: func() any { return &models.GalleryItem{} }},
+	"projects":       {Type: "project", KeyColumn: "id", New: func() any { return &models.Project{} }},
+	"map-icon-types": {Type: "map_icon_type", KeyColumn: "id", New: func() any { return &models.MapIconType{} }},
+	"map-icons":      {Type: "map_icon", KeyColumn: "id", New: func() any { return &models.MapIcon{} }},
+	"submissions":    {Type: "contact_submission", KeyColumn: "id", New: func() any { return &models.ContactSubmission{} }},
+	"challenges":     {Type: "challenge", KeyColumn: "id", New: func() any { return &models.Challenge{} }},
+	"settings":       {Type: "site_setting", KeyColumn: "key", New: func() any { return &models.SiteSetting{} }},
+	"reservations":   {Type: "reservation", KeyColumn: "id", New: func() any { return &models.Reservation{} }},
+	"exchange-rates": {Type: "exchange_rate", KeyColumn: "currency", New: func() any { return &models.ExchangeRate{} }},
+	"users":          {Type: "admin_user", KeyColumn: "id", New: func() any { return &models.AdminUser{} }},
+	"api-keys":       {Type: "api_key", KeyColumn: "id", New: func() any { return &models.APIKey{} }},
+	"media":          {Type: "media_asset", KeyColumn: "id", New: func() any { return &models.MediaAsset{} }},
+}
+
+// auditBatchActions list the keys a collection action such as
+// /settings/bulk touches, so each of them gets its own entry and diff.
+var auditBatchActions = map[string]map[string]func(c *fiber.Ctx) []string{
+	"settings": {
+		"bulk": bulkSettingKeys,
+		"seed": seedSettingKeys,
+	},
+}
+
+// bulkSettingKeys reads the keys of a {"settings": [{"key": ...}]} body.
+func bulkSettingKeys(c *fiber.Ctx) []string {
+	var req struct {
+		Settings []struct {
+			Key string `json:"key"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(req.Settings))
+	for _, item := range req.Settings {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
+
+// seedSettingKeys covers every stored setting and every default, since a
+// reset drops the former and recreates the latter.
+func seedSettingKeys(c *fiber.Ctx) []string {
+	var keys []string
+	database.DB.Model(&models.SiteSetting{}).Pluck("key", &keys)
+	for _, setting := range models.DefaultSettings() {
+		keys = append(keys, setting.Key)
+	}
+	return keys
+}
+
+// Fields that change on every write and would only add noise to the diff.
+var auditIgnoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// AuditChange is the before/after pair of one changed field.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditLog records every mutating request on the group it is mounted on,
+// with a field-level diff for known entities. Mount it after AuthRequired.
+func AuditLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		method := c.Method()
+		if method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions {
+			return c.Next()
+		}
+
+		entity, entityID, batch, known := resolveAuditEntity(c.Path())
+		var batchKeys []string
+		var before map[string]any
+		var batchBefore map[string]map[string]any
+		switch {
+		case batch != nil:
+			batchKeys = uniqueKeys(batch(c))
+			batchBefore = loadAuditSnapshots(entity, batchKeys)
+		case known && entityID != "":
+			before = loadAuditSnapshot(entity, entityID)
+		}
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		entry := models.AuditLog{
+			Username:  auditUsername(c),
+			Method:    method,
+			Route:     c.Path(),
+			Status:    status,
+			IPAddress: c.IP(),
+		}
+
+		entries := []models.AuditLog{entry}
+		switch {
+		case batch != nil:
+			entries[0].EntityType = entity.Type
+			if status < 400 {
+				if changed := batchAuditEntries(entry, entity, batchKeys, batchBefore); len(changed) > 0 {
+					entries = changed
+				}
+			}
+		case known:
+			entries[0].EntityType = entity.Type
+			// Creates have no id in the path; take it from the response.
+			if entityID == "" && status < 400 {
+				entityID = responseEntityID(c.Response().Body(), entity.KeyColumn)
+			}
+			entries[0].EntityID = entityID
+
+			if status < 400 && entityID != "" {
+				after := loadAuditSnapshot(entity, entityID)
+				entries[0].Changes = encodeAuditChanges(diffAuditSnapshots(before, after))
+			}
+		}
+
+		if dbErr := database.DB.Create(&entries).Error; dbErr != nil {
+			log.Printf("[Audit] failed to record %s %s: %v", method, entry.Route, dbErr)
+		}
+
+		return err
+	}
+}
+
+// batchAuditEntries builds one entry per key the request actually changed.
+func batchAuditEntries(entry models.AuditLog, entity auditEntity, keys []string, before map[string]map[string]any) []models.AuditLog {
+	after := loadAuditSnapshots(entity, keys)
+	var entries []models.AuditLog
+	for _, key := range keys {
+		changes := encodeAuditChanges(diffAuditSnapshots(before[key], after[key]))
+		if changes == "" {
+			continue
+		}
+		item := entry
+		item.EntityType = entity.Type
+		item.EntityID = key
+		item.Changes = changes
+		entries = append(entries, item)
+	}
+	return entries
+}
+
+func encodeAuditChanges(changes map[string]AuditChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	out := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+func auditUsername(c *fiber.Ctx) string {
+	username, _ := c.Locals("username").(string)
+	return username
+}
+
+// resolveAuditEntity splits /api/admin/<resource>/<id>/... into the entity
+// and the raw id segment, or the key lister of a batch action.
+func resolveAuditEntity(path string) (auditEntity, string, func(*fiber.Ctx) []string, bool) {
+	rest := strings.TrimPrefix(path, "/api/admin/")
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	entity, ok := auditEntities[parts[0]]
+	if !ok {
+		return auditEntity{}, "", nil, false
+	}
+	if len(parts) < 2 {
+		return entity, "", nil, true
+	}
+
+	id := parts[1]
+	if batch, ok := auditBatchActions[parts[0]][id]; ok && len(parts) == 2 {
+		return entity, "", batch, true
+	}
+	if entity.KeyColumn == "id" {
+		if _, err := strconv.ParseUint(id, 10, 32); err != nil {
+			// Collection actions such as /gallery/upload or /gallery/reorder
+			return entity, "", nil, true
+		}
+	}
+	if entity.KeyColumn == "currency" {
+		id = strings.ToUpper(id)
+	}
+	return entity, id, nil, true
+}
+
+// loadAuditSnapshot returns the entity as its JSON representation, or nil.
+func loadAuditSnapshot(entity auditEntity, id string) map[string]any {
+	record := entity.New()
+	if err := database.DB.Where(entity.KeyColumn+" = ?", id).First(record).Error; err != nil {
+		return nil
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var snapshot map[string]any
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// loadAuditSnapshots loads several entities at once, keyed by KeyColumn.
+func loadAuditSnapshots(entity auditEntity, ids []string) map[string]map[string]any {
+	snapshots := make(map[string]map[string]any, len(ids))
+	if len(ids) == 0 {
+		return snapshots
+	}
+	records := reflect.New(reflect.SliceOf(reflect.TypeOf(entity.New()).Elem()))
+	if err := database.DB.Where(entity.KeyColumn+" IN ?", ids).Find(records.Interface()).Error; err != nil {
+		return snapshots
+	}
+	encoded, err := json.Marshal(records.Interface())
+	if err != nil {
+		return snapshots
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(encoded, &items); err != nil {
+		return snapshots
+	}
+	for _, item := range items {
+		snapshots[fmt.Sprint(item[entity.KeyColumn])] = item
+	}
+	return snapshots
+}
+
+func responseEntityID(body []byte, keyColumn string) string {
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	switch value := payload[keyColumn].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatInt(int64(value), 10)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// diffAuditSnapshots lists changed fields. A create has no before snapshot
+// and a delete has no after snapshot, so every field shows up.
+func diffAuditSnapshots(before, after map[string]any) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+	for key, value := range before {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if next, ok := after[key]; !ok || !reflect.DeepEqual(value, next) {
+			changes[key] = AuditChange{Before: value, After: after[key]}
+		}
+	}
+	for key, value := range after {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if _, ok := before[key]; !ok {
+			changes[key] = AuditChange{Before: nil, After: value}
+		}
+	}
+	return changes
+}
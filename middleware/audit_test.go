@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"eman-backend/database"
+	"eman-backend/models"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestResolveAuditEntity(t *testing.T) {
+	tests := []struct {
+		path       string
+		entityType string
+		id         string
+		batch      bool
+	}{
+		{"/api/admin/gallery/12", "gallery_item", "12", false},
+		{"/api/admin/gallery/reorder", "gallery_item", "", false},
+		{"/api/admin/settings/phone", "site_setting", "phone", false},
+		{"/api/admin/settings/bulk", "site_setting", "", true},
+		{"/api/admin/settings/seed", "site_setting", "", true},
+		{"/api/admin/exchange-rates/usd", "exchange_rate", "USD", false},
+		{"/api/admin/media/7", "media_asset", "7", false},
+		{"/api/admin/media/7/restore", "media_asset", "7", false},
+	}
+	for _, tt := range tests {
+		entity, id, batch, known := resolveAuditEntity(tt.path)
+		if !known || entity.Type != tt.entityType || id != tt.id || (batch != nil) != tt.batch {
+			t.Errorf("%s: got %q %q batch=%v known=%v", tt.path, entity.Type, id, batch != nil, known)
+		}
+	}
+	if _, _, _, known := resolveAuditEntity("/api/admin/me/password"); known {
+		t.Error("/me was resolved to an entity")
+	}
+}
+
+func TestAuditBulkSettingsPerKey(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.SiteSetting{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	keys := []string{"audit_test_a", "audit_test_b", "audit_test_c"}
+	for _, key := range keys {
+		db.Where("key = ?", key).Delete(&models.SiteSetting{})
+		if err := db.Create(&models.SiteSetting{Key: key, Value: "old", Type: models.TypeString}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Where("key IN ?", keys).Delete(&models.SiteSetting{})
+		db.Where("entity_id IN ?", keys).Delete(&models.AuditLog{})
+	})
+
+	app := fiber.New()
+	app.Use(AuditLog())
+	app.Post("/api/admin/settings/bulk", func(c *fiber.Ctx) error {
+		db.Model(&models.SiteSetting{}).Where("key IN ?", keys[:2]).Update("value", "new")
+		return c.JSON(fiber.Map{"success": true})
+	})
+
+	body := `{"settings":[{"key":"audit_test_a","value":"new"},{"key":"audit_test_b","value":"new"},{"key":"audit_test_c","value":"old"}]}`
+	req := httptest.NewRequest(fiber.MethodPost, "/api/admin/settings/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []models.AuditLog
+	db.Where("entity_id IN ?", keys).Order("entity_id").Find(&entries)
+	if len(entries) != 2 || entries[0].EntityID != "audit_test_a" || entries[1].EntityID != "audit_test_b" {
+		t.Fatalf("expected entries for the two changed keys, got %+v", entries)
+	}
+	if !strings.Contains(entries[0].Changes, `"before":"old"`) || !strings.Contains(entries[0].Changes, `"after":"new"`) {
+		t.Fatalf("missing value diff: %s", entries[0].Changes)
+	}
+}
@@ -1,20 +1,72 @@
 package middleware
 
 import (
-	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"eman-backend/services"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type Claims struct {
-	Username string `json:"username"`
-	jwt.RegisteredClaims
+// Identity is the admin behind a verified access token.
+type Identity struct {
+	Username  string
+	Role      string
+	AdminID   uint
+	SessionID uint
+	ExpiresAt time.Time
 }
 
-func AuthRequired(cfg *config.Config) fiber.Handler {
+// VerifyAccessToken validates an access token and re-checks the account and
+// session behind it. On failure it returns a user-facing message.
+func VerifyAccessToken(keys *services.JWTKeySet, tokenString string) (*Identity, string) {
+	claims, err := keys.Parse(tokenString)
+	if err != nil {
+		return nil, "Invalid or expired token"
+	}
+	if claims.Issuer != "eman-backend" {
+		return nil, "Invalid token claims"
+	}
+
+	// Re-check the account: disabled users and role changes take effect
+	// immediately instead of when the access token expires.
+	var admin models.AdminUser
+	if err := database.DB.Where("username = ?", claims.Username).First(&admin).Error; err != nil || admin.Disabled {
+		return nil, "Account is disabled or no longer exists"
+	}
+	if admin.Role != claims.Role {
+		return nil, "Role has changed, please sign in again"
+	}
+
+	// Access tokens die with their session (logout, revoke, password change)
+	var session models.AdminSession
+	if claims.SessionID == 0 ||
+		database.DB.First(&session, claims.SessionID).Error != nil ||
+		session.AdminUserID != admin.ID || session.RevokedAt != nil {
+		return nil, "Session has been revoked"
+	}
+
+	identity := &Identity{
+		Username:  claims.Username,
+		Role:      claims.Role,
+		AdminID:   admin.ID,
+		SessionID: session.ID,
+	}
+	if claims.ExpiresAt != nil {
+		identity.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return identity, ""
+}
+
+func AuthRequired(keys *services.JWTKeySet) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Integrations authenticate with an API key instead of a JWT
+		if apiKey := c.Get("X-API-Key"); apiKey != "" && c.Get("Authorization") == "" {
+			return authenticateAPIKey(c, apiKey)
+		}
+
 		// Get token from Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -33,31 +85,62 @@ func AuthRequired(cfg *config.Config) fiber.Handler {
 			})
 		}
 
-		tokenString := parts[1]
-
-		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		identity, msg := VerifyAccessToken(keys, parts[1])
+		if identity == nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error":   true,
-				"message": "Invalid or expired token",
+				"message": msg,
 			})
 		}
 
-		claims, ok := token.Claims.(*Claims)
-		if !ok || claims.Issuer != "eman-backend" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		// Store identity in context for handlers
+		c.Locals("username", identity.Username)
+		c.Locals("role", identity.Role)
+		c.Locals("admin_id", identity.AdminID)
+		c.Locals("session_id", identity.SessionID)
+
+		return c.Next()
+	}
+}
+
+// RequirePermission guards a route group: GET/HEAD need "<resource>:read",
+// every other method needs "<resource>:write".
+func RequirePermission(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		action := "write"
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			action = "read"
+		}
+
+		perm := resource + ":" + action
+		var allowed bool
+		if key, ok := c.Locals("api_key").(*models.APIKey); ok {
+			allowed = key.HasScope(perm)
+		} else {
+			role, _ := c.Locals("role").(string)
+			allowed = models.RoleHasPermission(role, perm)
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error":   true,
-				"message": "Invalid token claims",
+				"message": "Insufficient permissions",
 			})
 		}
 
-		// Store username in context for handlers
-		c.Locals("username", claims.Username)
+		return c.Next()
+	}
+}
 
+// RequireUser keeps API keys off routes that act on the signed-in admin's own
+// account (profile, password, sessions, 2FA).
+func RequireUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("api_key").(*models.APIKey); ok {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "Not available for API keys",
+			})
+		}
 		return c.Next()
 	}
 }
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"eman-backend/services"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PrivateUploads answers 404 for reserved keys under the static upload
+// prefix. The path is checked the way the file server resolves it: decoded,
+// cleaned and case-insensitively routed, so "%5F", "//" and "/./" do not
+// get around it.
+func PrivateUploads(prefix string) fiber.Handler {
+	prefix = strings.ToLower(strings.Trim(prefix, "/")) + "/"
+	return func(c *fiber.Ctx) error {
+		key := services.CleanKey(c.Path())
+		if strings.HasPrefix(strings.ToLower(key), prefix) && services.IsReservedKey(key[len(prefix):]) {
+			return fiber.ErrNotFound
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPrivateUploadsHidesReservedKeys(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"_quarantine/2025/01/evil.zip": "infected",
+		"2025/01/brochure.pdf":         "public",
+	} {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Use(PrivateUploads("/uploads"))
+	app.Static("/uploads", dir)
+
+	get := func(target string) (int, string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.URL.RawPath = target
+		req.URL.Path = target
+		req.RequestURI = target
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := get("/uploads/2025/01/brochure.pdf"); status != fiber.StatusOK || body != "public" {
+		t.Fatalf("public file: %d %q", status, body)
+	}
+	for _, target := range []string{
+		"/uploads/_quarantine/2025/01/evil.zip",
+		"/uploads/%5Fquarantine/2025/01/evil.zip",
+		"/uploads/%5fquarantine/2025/01/evil.zip",
+		"/uploads/%255Fquarantine/2025/01/evil.zip",
+		"/uploads//_quarantine/2025/01/evil.zip",
+		"/uploads/./_quarantine/2025/01/evil.zip",
+		"/uploads/2025/../_quarantine/2025/01/evil.zip",
+		"/UPLOADS/_quarantine/2025/01/evil.zip",
+	} {
+		if status, body := get(target); status != fiber.StatusNotFound || strings.Contains(body, "infected") {
+			t.Errorf("%s: %d %q", target, status, body)
+		}
+	}
+}
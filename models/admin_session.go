@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// AdminSession is one signed-in device. All refresh tokens issued by
+// rotating the login's token belong to the same session (token family).
+type AdminSession struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	AdminUserID   uint       `gorm:"index" json:"admin_user_id"`
+	UserAgent     string     `json:"user_agent"`
+	IPAddress     string     `gorm:"size:64" json:"ip_address"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	ExpiresAt     time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt     *time.Time `gorm:"index" json:"revoked_at"`
+	RevokedReason string     `gorm:"size:40" json:"revoked_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Session revoke reasons
+const (
+	SessionRevokedLogout         = "logout"
+	SessionRevokedByUser         = "revoked"
+	SessionRevokedPasswordChange = "password_changed"
+	SessionRevokedTokenReuse     = "token_reuse"
+	SessionRevokedAccountDisable = "account_disabled"
+)
+
+// Active reports whether the session can still be used.
+func (s *AdminSession) Active() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+// RefreshToken is a single-use refresh token. Only its SHA-256 hash is stored.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	SessionID uint      `gorm:"index"`
+	TokenHash string    `gorm:"uniqueIndex;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
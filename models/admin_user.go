@@ -2,12 +2,99 @@ package models
 
 import "time"
 
-// AdminUser represents the single admin account stored in the database.
+// AdminUser is a back-office account. Access is governed by Role.
 type AdminUser struct {
 	ID                uint       `gorm:"primaryKey" json:"id"`
 	Username          string     `gorm:"uniqueIndex;size:80" json:"username"`
 	PasswordHash      string     `gorm:"type:text" json:"-"`
+	Role              string     `gorm:"size:30" json:"role"` // owner, sales, content_editor
+	Disabled          bool       `json:"disabled"`
+	DisabledAt        *time.Time `json:"disabled_at,omitempty"`
 	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+	Email             string     `gorm:"size:200" json:"email"`           // password reset delivery
+	TelegramChatID    string     `gorm:"size:64" json:"telegram_chat_id"` // password reset delivery
+	TOTPSecret        string     `gorm:"size:64" json:"-"`
+	TOTPPendingSecret string     `gorm:"size:64" json:"-"` // awaiting the first valid code
+	TOTPEnabled       bool       `json:"totp_enabled"`
+	TOTPEnabledAt     *time.Time `json:"totp_enabled_at,omitempty"`
+	TOTPLastStep      int64      `json:"-"` // last accepted time step, blocks code replay
 	CreatedAt         time.Time  `json:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at"`
 }
+
+// Admin roles
+const (
+	RoleOwner         = "owner"
+	RoleSales         = "sales"
+	RoleContentEditor = "content_editor"
+)
+
+// Permissions are "<resource>:<read|write>" pairs. Route groups in
+// routes.Setup require the read permission for GET and write otherwise.
+const (
+	PermContentRead       = "content:read"
+	PermContentWrite      = "content:write"
+	PermSettingsRead      = "settings:read"
+	PermSettingsWrite     = "settings:write"
+	PermSubmissionsRead   = "submissions:read"
+	PermSubmissionsWrite  = "submissions:write"
+	PermReservationsRead  = "reservations:read"
+	PermReservationsWrite = "reservations:write"
+	PermUsersRead         = "users:read"
+	PermUsersWrite        = "users:write"
+	PermAuditRead         = "audit:read"
+	PermEstatesRead       = "estates:read" // the Macro estate catalogue
+)
+
+// rolePermissions lists what each role may do. The owner is allowed everything.
+var rolePermissions = map[string][]string{
+	RoleSales: {
+		PermSubmissionsRead, PermSubmissionsWrite,
+		PermReservationsRead, PermReservationsWrite,
+		PermContentRead,
+		PermSettingsRead,
+		PermEstatesRead,
+	},
+	RoleContentEditor: {
+		PermContentRead, PermContentWrite,
+		PermSettingsRead, PermSettingsWrite,
+	},
+}
+
+// ValidRole reports whether role is a known admin role.
+func ValidRole(role string) bool {
+	if role == RoleOwner {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission reports whether role grants perm.
+func RoleHasPermission(role, perm string) bool {
+	if role == RoleOwner {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions returns the permissions granted to role.
+func RolePermissions(role string) []string {
+	if role == RoleOwner {
+		return []string{
+			PermContentRead, PermContentWrite,
+			PermSettingsRead, PermSettingsWrite,
+			PermSubmissionsRead, PermSubmissionsWrite,
+			PermReservationsRead, PermReservationsWrite,
+			PermUsersRead, PermUsersWrite,
+			PermAuditRead,
+			PermEstatesRead,
+		}
+	}
+	return append([]string(nil), rolePermissions[role]...)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// APIKey authenticates a server-to-server integration. The secret is shown
+// once on creation or rotation; only its SHA-256 hash is stored.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"size:100" json:"name"`
+	Prefix     string     `gorm:"size:16" json:"prefix"` // leading characters, to recognise the key
+	KeyHash    string     `gorm:"uniqueIndex;size:64" json:"-"`
+	Scopes     string     `gorm:"type:text" json:"-"` // comma-separated permissions
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip"`
+	CreatedBy  string     `gorm:"size:80" json:"created_by"`
+	RotatedAt  *time.Time `json:"rotated_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// apiKeyScopes are the permissions a key may carry. Account and audit
+// management stay with human admins.
+var apiKeyScopes = []string{
+	PermEstatesRead,
+	PermSubmissionsRead, PermSubmissionsWrite,
+	PermReservationsRead, PermReservationsWrite,
+	PermContentRead, PermContentWrite,
+	PermSettingsRead, PermSettingsWrite,
+}
+
+// APIKeyScopes returns the scopes that can be granted to a key.
+func APIKeyScopes() []string {
+	return append([]string(nil), apiKeyScopes...)
+}
+
+// ValidAPIKeyScope reports whether scope can be granted to a key.
+func ValidAPIKeyScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeList splits the stored scopes.
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope reports whether the key grants perm.
+func (k *APIKey) HasScope(perm string) bool {
+	for _, s := range k.ScopeList() {
+		if s == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// HashAPIKey is how key secrets are stored and looked up.
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(raw)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Active reports whether the key can still authenticate.
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(time.Now()))
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// AuditLog is one mutating admin request.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Username   string    `gorm:"index;size:80" json:"username"`
+	Method     string    `gorm:"size:10" json:"method"`
+	Route      string    `gorm:"size:255" json:"route"`
+	Status     int       `json:"status"`
+	EntityType string    `gorm:"index:idx_audit_entity;size:50" json:"entity_type"`
+	EntityID   string    `gorm:"index:idx_audit_entity;size:100" json:"entity_id"`
+	IPAddress  string    `gorm:"size:64" json:"ip_address"`
+	Changes    string    `gorm:"type:text" json:"changes,omitempty"` // JSON {field: {before, after}}
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// BackplaneChannel numbers the messages of one backplane channel, so every
+// instance sees the same sequence. Epoch changes only if the row is lost.
+type BackplaneChannel struct {
+	Channel string `gorm:"primaryKey;size:63"`
+	LastSeq uint64
+	Epoch   string `gorm:"size:32"`
+}
+
+// BackplaneMessage is a published message, kept so instances can catch up
+// after a dropped LISTEN connection. Only the latest ones are retained.
+type BackplaneMessage struct {
+	Channel   string `gorm:"primaryKey;size:63"`
+	Seq       uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Payload   string `gorm:"type:text"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ExchangeRate stores how many units of the base currency one unit of Currency costs.
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"uniqueIndex;size:10" json:"currency"` // ISO code, e.g. USD
+	Rate      float64   `json:"rate"`
+	Source    string    `gorm:"size:20" json:"source"` // manual, import
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExchangeRate sources
+const (
+	RateSourceManual = "manual"
+	RateSourceImport = "import"
+)
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// LoginAttempt records every admin sign-in attempt and password reset.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"index;size:100" json:"username"`
+	IPAddress string    `gorm:"index;size:64" json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `gorm:"size:40" json:"reason,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Login attempt outcomes
+const (
+	LoginReasonOK                 = "ok"
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonInvalid2FA         = "invalid_2fa"
+	LoginReasonDisabled           = "disabled"
+	LoginReasonLocked             = "locked"
+	LoginReasonThrottled          = "throttled"
+	LoginReasonResetRequested     = "password_reset_requested"
+	LoginReasonResetUnavailable   = "password_reset_unavailable"
+	LoginReasonResetCompleted     = "password_reset"
+	LoginReasonResetInvalidToken  = "invalid_reset_token"
+)
+
+// LoginLockout tracks consecutive failures for a username or an IP.
+type LoginLockout struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Scope         string     `gorm:"uniqueIndex:idx_login_lockout_key;size:20" json:"scope"` // username, ip
+	Key           string     `gorm:"uniqueIndex:idx_login_lockout_key;size:100" json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Lockout scopes
+const (
+	LockoutScopeUsername = "username"
+	LockoutScopeIP       = "ip"
+)
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// MediaAsset is an uploaded file. RefCount mirrors its MediaReference rows;
+// UnreferencedSince is set while nothing uses the file and drives cleanup.
+type MediaAsset struct {
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	Path              string         `gorm:"uniqueIndex;size:500" json:"path"` // storage key
+	OriginalName      string         `gorm:"size:255" json:"original_name"`
+	Size              int64          `json:"size"`
+	MimeType          string         `gorm:"index;size:100" json:"mime_type"`
+	Width             int            `json:"width,omitempty"`
+	Height            int            `json:"height,omitempty"`
+	Checksum          string         `gorm:"index;size:64" json:"checksum"` // SHA-256, hex
+	Variants          []MediaVariant `gorm:"serializer:json;type:text" json:"variants"`
+	UploadedBy        string         `gorm:"index;size:80" json:"uploaded_by"`
+	RefCount          int            `gorm:"index;not null;default:0" json:"ref_count"`
+	UnreferencedSince *time.Time     `gorm:"index" json:"unreferenced_since,omitempty"`
+	ScanStatus        string         `gorm:"index;size:20;not null;default:''" json:"scan_status,omitempty"`
+	ScanSignature     string         `gorm:"size:255" json:"scan_signature,omitempty"`
+	ScannedAt         *time.Time     `json:"scanned_at,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+
+	URL string `gorm:"-" json:"url"`
+}
+
+// Malware scan results; empty when scanning is off
+const (
+	ScanClean    = "clean"
+	ScanInfected = "infected" // the file is kept in quarantine
+	ScanRestored = "restored" // released from quarantine by an admin
+	ScanSkipped  = "skipped"  // stored unscanned by earlier versions; oversized uploads are now refused
+	ScanFailed   = "failed"   // scanner unreachable, kept because of SCAN_FAIL_OPEN
+)
+
+// MediaVariant is a downscaled WebP copy of an image asset.
+type MediaVariant struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Path   string `json:"path"`
+	URL    string `json:"url,omitempty"` // filled in for responses
+}
+
+// MediaReference records that a field of an entity points at an asset.
+// Settings use their key as EntityID.
+type MediaReference struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	AssetID    uint      `gorm:"index;not null" json:"asset_id"`
+	EntityType string    `gorm:"index:idx_media_ref_entity;size:50;not null" json:"entity_type"`
+	EntityID   string    `gorm:"index:idx_media_ref_entity;size:100;not null" json:"entity_id"`
+	Field      string    `gorm:"size:100" json:"field"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Asset *MediaAsset `gorm:"foreignKey:AssetID;constraint:OnDelete:CASCADE" json:"-"`
+}
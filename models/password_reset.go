@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single-use token that lets an admin set a new
+// password without signing in. Only its hash is stored.
+type PasswordResetToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	AdminUserID uint       `gorm:"index" json:"admin_user_id"`
+	TokenHash   string     `gorm:"uniqueIndex;size:64" json:"-"`
+	Channel     string     `gorm:"size:20" json:"channel"` // telegram, email
+	RequestIP   string     `gorm:"size:64" json:"request_ip"`
+	ExpiresAt   time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Password reset delivery channels
+const (
+	ResetChannelTelegram = "telegram"
+	ResetChannelEmail    = "email"
+)
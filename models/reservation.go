@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Reservation is a temporary hold on a Macro estate.
+type Reservation struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EstateID      int        `gorm:"index;uniqueIndex:idx_reservations_active_estate,where:status = 'active'" json:"estate_id"` // one active hold per estate
+	Status        string     `gorm:"index;size:20" json:"status"`                                                               // active, released, expired
+	ExpiresAt     time.Time  `gorm:"index" json:"expires_at"`
+	CreatedBy     string     `gorm:"size:80" json:"created_by"`
+	SubmissionID  *uint      `gorm:"index" json:"submission_id"`
+	Notes         string     `json:"notes"`
+	ReleasedAt    *time.Time `json:"released_at"`
+	ReleasedBy    string     `gorm:"size:80" json:"released_by"`
+	MacroSyncedAt *time.Time `json:"macro_synced_at"`
+	MacroError    string     `json:"macro_error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Submission *ContactSubmission `gorm:"foreignKey:SubmissionID" json:"submission,omitempty"`
+}
+
+// Reservation statuses
+const (
+	ReservationActive   = "active"
+	ReservationReleased = "released"
+	ReservationExpired  = "expired"
+)
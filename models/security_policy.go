@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AdminRecoveryCode is a single-use 2FA fallback code. Only its hash is stored.
+type AdminRecoveryCode struct {
+	ID          uint   `gorm:"primaryKey"`
+	AdminUserID uint   `gorm:"index"`
+	CodeHash    string `gorm:"size:64"`
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
+
+// SecurityPolicy holds owner-controlled account security rules. There is a
+// single row; it is kept apart from SiteSetting because those are public.
+type SecurityPolicy struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	Require2FA bool      `gorm:"column:require_2fa" json:"require_2fa"`
+	UpdatedBy  string    `gorm:"size:80" json:"updated_by"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
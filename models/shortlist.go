@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Shortlist is an anonymous list of favourite estates identified by a share token.
+type Shortlist struct {
+	ID        uint            `gorm:"primaryKey" json:"-"`
+	Token     string          `gorm:"uniqueIndex;size:64" json:"token"`
+	IPAddress string          `json:"-"`
+	UserAgent string          `json:"-"`
+	Items     []ShortlistItem `gorm:"constraint:OnDelete:CASCADE;" json:"items"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type ShortlistItem struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	ShortlistID uint      `gorm:"uniqueIndex:idx_shortlist_estate" json:"-"`
+	EstateID    int       `gorm:"uniqueIndex:idx_shortlist_estate" json:"estate_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -65,14 +65,22 @@ func DefaultSettings() []SiteSetting {
 				"description": "Удобное финансирование для покупки квартиры вашей мечты",
 				"price": "1 млн сум",
 				"period": "В месяц",
-				"features": ["Первоначальный взнос от 30%", "Срок до 36 месяцев", "Без процентов"]
+				"features": ["Первоначальный взнос от 30%", "Срок до 36 месяцев", "Без процентов"],
+				"min_down_payment_percent": 30,
+				"term_months": 36,
+				"interest_rate": 0,
+				"discount_percent": 0
 			},
 			{
 				"title": "Рассрочка",
 				"description": "Гибкие условия рассрочки без дополнительных платежей",
 				"price": "2 млн сум",
 				"period": "В месяц",
-				"features": ["Первоначальный взнос от 20%", "Срок до 24 месяцев", "Скидка 5%"]
+				"features": ["Первоначальный взнос от 20%", "Срок до 24 месяцев", "Скидка 5%"],
+				"min_down_payment_percent": 20,
+				"term_months": 24,
+				"interest_rate": 0,
+				"discount_percent": 5
 			}
 		]`, Type: TypeJSON, Category: CategoryPricing, Label: "Планы оплаты", LabelUz: "To'lov rejalari"},
 		{Key: "payment_plans_uz", Value: `[
@@ -81,14 +89,22 @@ func DefaultSettings() []SiteSetting {
 				"description": "Orzu qilgan kvartirangizni sotib olish uchun qulay moliyalashtirish",
 				"price": "1 mln so'm",
 				"period": "Oyiga",
-				"features": ["Boshlang'ich to'lov 30% dan", "Muddat 36 oygacha", "Foizsiz"]
+				"features": ["Boshlang'ich to'lov 30% dan", "Muddat 36 oygacha", "Foizsiz"],
+				"min_down_payment_percent": 30,
+				"term_months": 36,
+				"interest_rate": 0,
+				"discount_percent": 0
 			},
 			{
 				"title": "Bo'lib to'lash",
 				"description": "Qo'shimcha to'lovsiz moslashuvchan bo'lib to'lash shartlari",
 				"price": "2 mln so'm",
 				"period": "Oyiga",
-				"features": ["Boshlang'ich to'lov 20% dan", "Muddat 24 oygacha", "5% chegirma"]
+				"features": ["Boshlang'ich to'lov 20% dan", "Muddat 24 oygacha", "5% chegirma"],
+				"min_down_payment_percent": 20,
+				"term_months": 24,
+				"interest_rate": 0,
+				"discount_percent": 5
 			}
 		]`, Type: TypeJSON, Category: CategoryPricing, Label: "Планы оплаты (UZ)", LabelUz: "To'lov rejalari (UZ)"},
 		{Key: "payment_plans_en", Value: `[
@@ -97,14 +113,22 @@ func DefaultSettings() []SiteSetting {
 				"description": "Convenient financing for the apartment of your dreams",
 				"price": "1 million UZS",
 				"period": "Per month",
-				"features": ["Initial payment from 30%", "Term up to 36 months", "Interest-free"]
+				"features": ["Initial payment from 30%", "Term up to 36 months", "Interest-free"],
+				"min_down_payment_percent": 30,
+				"term_months": 36,
+				"interest_rate": 0,
+				"discount_percent": 0
 			},
 			{
 				"title": "Installment",
 				"description": "Flexible installment terms without additional payments",
 				"price": "2 million UZS",
 				"period": "Per month",
-				"features": ["Initial payment from 20%", "Term up to 24 months", "5% discount"]
+				"features": ["Initial payment from 20%", "Term up to 24 months", "5% discount"],
+				"min_down_payment_percent": 20,
+				"term_months": 24,
+				"interest_rate": 0,
+				"discount_percent": 5
 			}
 		]`, Type: TypeJSON, Category: CategoryPricing, Label: "Планы оплаты (EN)", LabelUz: "To'lov rejalari (EN)"},
 
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UploadSession is a resumable (tus) upload in progress. Each PATCH is kept
+// as a separate chunk in storage until the last one arrives and the chunks
+// are assembled into the final file.
+type UploadSession struct {
+	ID          string     `gorm:"primaryKey;size:36" json:"id"`
+	Filename    string     `gorm:"size:255" json:"filename"`
+	ContentType string     `gorm:"size:100" json:"content_type"`
+	Length      int64      `json:"length"`
+	Received    int64      `json:"offset"`
+	Parts       []string   `gorm:"serializer:json;type:text" json:"-"` // name of each stored chunk, in order
+	Checksum    string     `gorm:"size:64" json:"checksum,omitempty"`  // expected SHA-256 of the whole file, hex
+	CreatedBy   string     `gorm:"index;size:80" json:"created_by"`
+	ResultPath  string     `gorm:"size:500" json:"result_path,omitempty"`
+	Error       string     `gorm:"size:255" json:"error,omitempty"`
+	ExpiresAt   time.Time  `gorm:"index" json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
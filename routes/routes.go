@@ -5,6 +5,7 @@ import (
 	"eman-backend/handlers"
 	"eman-backend/middleware"
 	"eman-backend/services"
+	ws "eman-backend/websocket"
 	"log"
 
 	"github.com/gofiber/contrib/websocket"
@@ -13,26 +14,64 @@ import (
 
 func Setup(app *fiber.App, cfg *config.Config) {
 	// Services
+	jwtKeys, err := services.NewJWTKeySet(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	backplane := services.NewBackplane(cfg)
+	ws.GetHub().SetBackplane(backplane)
 	macroService := services.NewMacroService(cfg)
-	storageService := services.NewStorageService(cfg)
+	storageBackend, err := services.NewStorageBackend(cfg, cfg.StorageBackend)
+	if err != nil {
+		log.Fatalf("Failed to set up upload storage: %v", err)
+	}
+	storageService := services.NewStorageService(cfg, storageBackend, services.NewScanner(cfg))
+	offerService := services.NewCommercialOfferService()
+	currencyService := services.NewCurrencyService(cfg)
+	reservationService := services.NewReservationService(cfg, macroService)
+	sessionService := services.NewSessionService(cfg)
+	twoFactorService := services.NewTwoFactorService(cfg)
+	auditService := services.NewAuditService(cfg)
+	apiKeyService := services.NewAPIKeyService()
 	telegramService := services.NewTelegramService(cfg.TelegramBotToken, cfg.TelegramChatID)
 	if telegramService.Enabled() {
 		log.Printf("[Telegram] notifications enabled for chat_id=%s", cfg.TelegramChatID)
 	} else {
 		log.Printf("[Telegram] notifications disabled (TELEGRAM_BOT_TOKEN or TELEGRAM_CHAT_ID is empty)")
 	}
+	loginGuardService := services.NewLoginGuardService(cfg, telegramService)
+	mailerService := services.NewMailerService(cfg)
+	passwordResetService := services.NewPasswordResetService(cfg, telegramService, mailerService, sessionService)
+	contentEvents := services.NewContentEventService(cfg)
+	imageService := services.NewImageService(cfg, storageService)
+	mediaService := services.NewMediaService(cfg, storageService, imageService)
+	resumableUploadService := services.NewResumableUploadService(cfg, storageService)
 
 	// Handlers
-	estateHandler := handlers.NewEstateHandler(macroService)
-	authHandler := handlers.NewAuthHandler(cfg)
-	galleryHandler := handlers.NewGalleryHandler(storageService)
-	projectsHandler := handlers.NewProjectsHandler(storageService)
+	estateHandler := handlers.NewEstateHandler(macroService, currencyService, reservationService)
+	authHandler := handlers.NewAuthHandler(cfg, jwtKeys, sessionService, loginGuardService, twoFactorService, passwordResetService)
+	galleryHandler := handlers.NewGalleryHandler(storageService, contentEvents, mediaService)
+	projectsHandler := handlers.NewProjectsHandler(storageService, contentEvents, mediaService)
 	submissionsHandler := handlers.NewSubmissionsHandler(macroService, telegramService)
-	settingsHandler := handlers.NewSettingsHandler()
-	uploadHandler := handlers.NewUploadHandler(storageService)
-	mapIconHandler := handlers.NewMapIconHandler()
-	mapIconTypeHandler := handlers.NewMapIconTypeHandler(storageService)
-	challengesHandler := handlers.NewChallengesHandler(storageService)
+	settingsHandler := handlers.NewSettingsHandler(backplane, contentEvents, mediaService)
+	uploadHandler := handlers.NewUploadHandler(storageService, mediaService)
+	mapIconHandler := handlers.NewMapIconHandler(contentEvents)
+	mapIconTypeHandler := handlers.NewMapIconTypeHandler(storageService, contentEvents, mediaService)
+	challengesHandler := handlers.NewChallengesHandler(storageService, mediaService)
+	mediaHandler := handlers.NewMediaHandler(storageService, mediaService)
+	resumableUploadHandler := handlers.NewResumableUploadHandler(resumableUploadService, storageService, mediaService)
+	imageHandler := handlers.NewImageHandler(storageService, imageService)
+	calculatorHandler := handlers.NewCalculatorHandler(macroService)
+	offerHandler := handlers.NewOfferHandler(macroService, offerService)
+	exchangeRatesHandler := handlers.NewExchangeRatesHandler(currencyService)
+	reservationsHandler := handlers.NewReservationsHandler(reservationService, macroService)
+	shortlistsHandler := handlers.NewShortlistsHandler(macroService)
+	adminUsersHandler := handlers.NewAdminUsersHandler(sessionService, passwordResetService)
+	sessionsHandler := handlers.NewSessionsHandler(sessionService)
+	loginSecurityHandler := handlers.NewLoginSecurityHandler(loginGuardService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, sessionService)
+	auditLogsHandler := handlers.NewAuditLogsHandler(auditService)
+	apiKeysHandler := handlers.NewAPIKeysHandler(apiKeyService)
 
 	api := app.Group("/api")
 
@@ -47,6 +86,10 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	estate := api.Group("/estate")
 	estate.Get("/complexes", estateHandler.GetComplexes)
 	estate.Get("/list", estateHandler.GetEstates)
+	estate.Get("/:id/offer", offerHandler.Download)
+
+	// Exchange rates (public)
+	api.Get("/exchange-rates", exchangeRatesHandler.List)
 
 	// Gallery (public - only published)
 	api.Get("/gallery", galleryHandler.ListPublic)
@@ -57,6 +100,17 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	// Map icons (public)
 	api.Get("/map-icons", mapIconHandler.ListPublic)
 
+	// Shortlists (public, identified by share token)
+	shortlists := api.Group("/shortlists")
+	shortlists.Post("/", shortlistsHandler.Create)
+	shortlists.Get("/:token", shortlistsHandler.Get)
+	shortlists.Get("/:token/compare", shortlistsHandler.Compare)
+	shortlists.Post("/:token/items", shortlistsHandler.AddItem)
+	shortlists.Delete("/:token/items/:estateId", shortlistsHandler.RemoveItem)
+
+	// Payment calculator (public)
+	api.Post("/calculator", calculatorHandler.Calculate)
+
 	// Submissions (public - create only)
 	api.Post("/submissions", submissionsHandler.Create)
 
@@ -77,17 +131,44 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	auth.Post("/login", authHandler.Login)
 	auth.Post("/refresh", authHandler.Refresh)
 	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/2fa/verify", authHandler.VerifyTwoFactor)
+	auth.Post("/2fa/setup", authHandler.BeginTwoFactorSetup)
+	auth.Post("/2fa/setup/confirm", authHandler.ConfirmTwoFactorSetup)
+	auth.Post("/password-reset", authHandler.RequestPasswordReset)
+	auth.Post("/password-reset/confirm", authHandler.ConfirmPasswordReset)
+
+	// Public keys for verifying access tokens (asymmetric keys only)
+	app.Get("/.well-known/jwks.json", authHandler.JWKS)
 
 	// ============ ADMIN ROUTES (protected) ============
 
-	admin := api.Group("/admin", middleware.AuthRequired(cfg))
+	admin := api.Group("/admin", middleware.AuthRequired(jwtKeys), middleware.AuditLog())
 
-	// Auth - me
-	admin.Get("/me", authHandler.Me)
-	admin.Post("/password", authHandler.ChangePassword)
+	// Own account (every role, not API keys)
+	requireUser := middleware.RequireUser()
+	admin.Get("/me", requireUser, authHandler.Me)
+	admin.Post("/password", requireUser, authHandler.ChangePassword)
+	admin.Put("/me/contact", requireUser, authHandler.UpdateContact)
+
+	// Own sessions
+	admin.Get("/sessions", requireUser, sessionsHandler.List)
+	admin.Delete("/sessions", requireUser, sessionsHandler.RevokeOthers)
+	admin.Delete("/sessions/:id", requireUser, sessionsHandler.Revoke)
+
+	// Own two-factor authentication
+	admin.Get("/2fa", requireUser, twoFactorHandler.Status)
+	admin.Post("/2fa/setup", requireUser, twoFactorHandler.Setup)
+	admin.Post("/2fa/confirm", requireUser, twoFactorHandler.Confirm)
+	admin.Post("/2fa/disable", requireUser, twoFactorHandler.Disable)
+	admin.Post("/2fa/recovery-codes", requireUser, twoFactorHandler.RegenerateRecoveryCodes)
+
+	// Estate catalogue for integrations (same data as the public list)
+	adminEstates := admin.Group("/estates", middleware.RequirePermission("estates"))
+	adminEstates.Get("/complexes", estateHandler.GetComplexes)
+	adminEstates.Get("/list", estateHandler.GetEstates)
 
 	// Gallery management
-	adminGallery := admin.Group("/gallery")
+	adminGallery := admin.Group("/gallery", middleware.RequirePermission("content"))
 	adminGallery.Get("/", galleryHandler.List)
 	adminGallery.Get("/:id", galleryHandler.Get)
 	adminGallery.Post("/", galleryHandler.Create)
@@ -97,7 +178,7 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	adminGallery.Post("/reorder", galleryHandler.Reorder)
 
 	// Projects management
-	adminProjects := admin.Group("/projects")
+	adminProjects := admin.Group("/projects", middleware.RequirePermission("content"))
 	adminProjects.Get("/", projectsHandler.List)
 	adminProjects.Get("/:id", projectsHandler.Get)
 	adminProjects.Post("/", projectsHandler.Create)
@@ -106,7 +187,7 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	adminProjects.Post("/upload", projectsHandler.Upload)
 
 	// Map icon types management
-	adminMapIconTypes := admin.Group("/map-icon-types")
+	adminMapIconTypes := admin.Group("/map-icon-types", middleware.RequirePermission("content"))
 	adminMapIconTypes.Get("/", mapIconTypeHandler.List)
 	adminMapIconTypes.Post("/", mapIconTypeHandler.Create)
 	adminMapIconTypes.Put("/:id", mapIconTypeHandler.Update)
@@ -114,14 +195,14 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	adminMapIconTypes.Post("/upload", mapIconTypeHandler.Upload)
 
 	// Map icons management
-	adminMapIcons := admin.Group("/map-icons")
+	adminMapIcons := admin.Group("/map-icons", middleware.RequirePermission("content"))
 	adminMapIcons.Get("/", mapIconHandler.List)
 	adminMapIcons.Post("/", mapIconHandler.Create)
 	adminMapIcons.Put("/:id", mapIconHandler.Update)
 	adminMapIcons.Delete("/:id", mapIconHandler.Delete)
 
 	// Submissions management
-	adminSubmissions := admin.Group("/submissions")
+	adminSubmissions := admin.Group("/submissions", middleware.RequirePermission("submissions"))
 	adminSubmissions.Get("/", submissionsHandler.List)
 	adminSubmissions.Get("/stats", submissionsHandler.Stats)
 	adminSubmissions.Get("/:id", submissionsHandler.Get)
@@ -129,7 +210,7 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	adminSubmissions.Delete("/:id", submissionsHandler.Delete)
 
 	// Challenges management
-	adminChallenges := admin.Group("/challenges")
+	adminChallenges := admin.Group("/challenges", middleware.RequirePermission("content"))
 	adminChallenges.Get("/", challengesHandler.List)
 	adminChallenges.Get("/:id", challengesHandler.Get)
 	adminChallenges.Post("/", challengesHandler.Create)
@@ -139,7 +220,7 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	adminChallenges.Get("/:id/participants", challengesHandler.Participants)
 
 	// Settings management
-	adminSettings := admin.Group("/settings")
+	adminSettings := admin.Group("/settings", middleware.RequirePermission("settings"))
 	adminSettings.Get("/", settingsHandler.List)
 	adminSettings.Get("/categories", settingsHandler.GetCategories)
 	adminSettings.Get("/:key", settingsHandler.Get)
@@ -147,23 +228,101 @@ func Setup(app *fiber.App, cfg *config.Config) {
 	adminSettings.Post("/bulk", settingsHandler.BulkUpdate)
 	adminSettings.Post("/seed", settingsHandler.Seed)
 
+	// Reservations management
+	adminReservations := admin.Group("/reservations", middleware.RequirePermission("reservations"))
+	adminReservations.Get("/", reservationsHandler.List)
+	adminReservations.Post("/", reservationsHandler.Create)
+	adminReservations.Post("/:id/extend", reservationsHandler.Extend)
+	adminReservations.Post("/:id/release", reservationsHandler.Release)
+
+	// Exchange rates management
+	adminExchangeRates := admin.Group("/exchange-rates", middleware.RequirePermission("settings"))
+	adminExchangeRates.Get("/", exchangeRatesHandler.List)
+	adminExchangeRates.Post("/import", exchangeRatesHandler.Import)
+	adminExchangeRates.Put("/:currency", exchangeRatesHandler.Update)
+	adminExchangeRates.Delete("/:currency", exchangeRatesHandler.Delete)
+
 	// File upload (general purpose)
-	admin.Post("/upload", uploadHandler.Upload)
-	admin.Post("/upload/multiple", uploadHandler.UploadMultiple)
+	admin.Post("/upload", middleware.RequirePermission("content"), uploadHandler.Upload)
+	admin.Post("/upload/multiple", middleware.RequirePermission("content"), uploadHandler.UploadMultiple)
+
+	// Resumable uploads for large files (tus 1.0)
+	adminResumable := admin.Group("/uploads/resumable", middleware.RequirePermission("content"))
+	adminResumable.Options("/", resumableUploadHandler.Options)
+	adminResumable.Post("/", resumableUploadHandler.Create)
+	adminResumable.Head("/:id", resumableUploadHandler.Head)
+	adminResumable.Get("/:id", resumableUploadHandler.Get)
+	adminResumable.Patch("/:id", resumableUploadHandler.Patch)
+	adminResumable.Delete("/:id", resumableUploadHandler.Terminate)
+
+	// Media library
+	adminMedia := admin.Group("/media", middleware.RequirePermission("content"))
+	adminMedia.Get("/", mediaHandler.List)
+	adminMedia.Get("/quarantine", mediaHandler.Quarantine)
+	adminMedia.Get("/:id", mediaHandler.Get)
+	adminMedia.Post("/:id/restore", mediaHandler.Restore)
+	adminMedia.Delete("/:id", mediaHandler.Delete)
+
+	// Admin accounts management
+	adminUsers := admin.Group("/users", middleware.RequirePermission("users"))
+	adminUsers.Get("/", adminUsersHandler.List)
+	adminUsers.Post("/", adminUsersHandler.Create)
+	adminUsers.Put("/:id", adminUsersHandler.Update)
+	adminUsers.Post("/:id/disable", adminUsersHandler.Disable)
+	adminUsers.Post("/:id/enable", adminUsersHandler.Enable)
+	adminUsers.Post("/:id/password", adminUsersHandler.ResetPassword)
+	adminUsers.Post("/:id/2fa/reset", twoFactorHandler.ResetForUser)
+
+	// Login security (lockouts and sign-in history)
+	adminSecurity := admin.Group("/security", middleware.RequirePermission("users"))
+	adminSecurity.Get("/lockouts", loginSecurityHandler.Lockouts)
+	adminSecurity.Delete("/lockouts/:id", loginSecurityHandler.ClearLockout)
+	adminSecurity.Get("/login-attempts", loginSecurityHandler.Attempts)
+	adminSecurity.Get("/policy", twoFactorHandler.Policy)
+	adminSecurity.Put("/policy", twoFactorHandler.UpdatePolicy)
+
+	// API keys for integrations
+	adminAPIKeys := admin.Group("/api-keys", middleware.RequirePermission("users"))
+	adminAPIKeys.Get("/", apiKeysHandler.List)
+	adminAPIKeys.Post("/", apiKeysHandler.Create)
+	adminAPIKeys.Post("/:id/rotate", apiKeysHandler.Rotate)
+	adminAPIKeys.Delete("/:id", apiKeysHandler.Revoke)
+
+	// Audit log
+	admin.Get("/audit-logs", middleware.RequirePermission("audit"), auditLogsHandler.List)
 
 	// Serve uploaded files with byte-range support for large media
-	app.Static("/uploads", cfg.UploadDir, fiber.Static{
-		ByteRange: true,
-		MaxAge:    31536000,
-		ModifyResponse: func(c *fiber.Ctx) error {
-			c.Set("Cache-Control", "public, max-age=31536000, immutable")
-			return nil
-		},
-	})
+	if local, ok := storageBackend.(*services.LocalStorage); ok {
+		// Quarantined files and resumable upload chunks stay private
+		app.Use(middleware.PrivateUploads("/uploads"))
+		app.Static("/uploads", local.Dir(), fiber.Static{
+			ByteRange: true,
+			MaxAge:    31536000,
+			ModifyResponse: func(c *fiber.Ctx) error {
+				c.Set("Cache-Control", "public, max-age=31536000, immutable")
+				c.Set("X-Content-Type-Options", "nosniff")
+				if services.ServeAsAttachment(c.Path()) {
+					c.Set("Content-Disposition", "attachment")
+				}
+				return nil
+			},
+		})
+	} else {
+		// Links saved before the move to object storage still point at /uploads
+		app.Get("/uploads/*", uploadHandler.Redirect)
+	}
+
+	// Resized images, e.g. /img/480x0/2025/01/20250101_ab12cd34.webp
+	app.Get("/img/:size/*", imageHandler.Resize)
 
 	// ============ WEBSOCKET ============
-	wsHandler := handlers.NewWebSocketHandler()
+	// Anonymous sockets may follow public.* topics; admin.* topics need an
+	// access token in ?token= or an {"type":"auth"} message.
+	wsHandler := handlers.NewWebSocketHandler(jwtKeys)
 
 	app.Use("/ws", wsHandler.Upgrade)
 	app.Get("/ws", websocket.New(wsHandler.Handle))
+
+	// Server-Sent Events fallback with the same auth, topics and resume
+	app.Get("/events", wsHandler.Stream)
 }
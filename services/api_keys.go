@@ -0,0 +1,118 @@
+package services
+
+import (
+	"crypto/rand"
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrAPIKeyRevoked = errors.New("api key is revoked")
+
+const apiKeyPrefix = "eman_"
+
+// APIKeyService issues, rotates and revokes integration keys.
+type APIKeyService struct{}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{}
+}
+
+func newAPIKeySecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// NormalizeScopes validates and de-duplicates requested scopes.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !models.ValidAPIKeyScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		seen[scope] = true
+		out = append(out, scope)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return out, nil
+}
+
+// Create stores a new key and returns its secret, which is not kept.
+func (s *APIKeyService) Create(name string, scopes []string, expiresAt *time.Time, createdBy string) (string, *models.APIKey, error) {
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := models.APIKey{
+		Name:      strings.TrimSpace(name),
+		Prefix:    secret[:len(apiKeyPrefix)+6],
+		KeyHash:   models.HashAPIKey(secret),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		return "", nil, err
+	}
+	return secret, &key, nil
+}
+
+// Rotate replaces the secret of an active key; the old one stops working at once.
+func (s *APIKeyService) Rotate(id uint) (string, *models.APIKey, error) {
+	var key models.APIKey
+	if err := database.DB.First(&key, id).Error; err != nil {
+		return "", nil, err
+	}
+	if key.RevokedAt != nil {
+		return "", nil, ErrAPIKeyRevoked
+	}
+
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	key.Prefix = secret[:len(apiKeyPrefix)+6]
+	key.KeyHash = models.HashAPIKey(secret)
+	key.RotatedAt = &now
+	if err := database.DB.Model(&key).Updates(map[string]interface{}{
+		"prefix":     key.Prefix,
+		"key_hash":   key.KeyHash,
+		"rotated_at": key.RotatedAt,
+	}).Error; err != nil {
+		return "", nil, err
+	}
+	return secret, &key, nil
+}
+
+// Revoke disables a key permanently.
+func (s *APIKeyService) Revoke(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := database.DB.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := database.DB.Model(&key).Update("revoked_at", key.RevokedAt).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &key, nil
+}
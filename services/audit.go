@@ -0,0 +1,54 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"log"
+	"time"
+)
+
+const auditCleanupInterval = 24 * time.Hour
+
+// AuditService enforces the audit log retention period.
+type AuditService struct {
+	cfg *config.Config
+}
+
+func NewAuditService(cfg *config.Config) *AuditService {
+	service := &AuditService{cfg: cfg}
+	service.startCleanupJob()
+	return service
+}
+
+// Retention is how long entries are kept; zero means forever.
+func (s *AuditService) Retention() time.Duration {
+	return s.cfg.AuditLogRetention
+}
+
+func (s *AuditService) startCleanupJob() {
+	if s.cfg.AuditLogRetention <= 0 {
+		return
+	}
+	go func() {
+		s.purge()
+		ticker := time.NewTicker(auditCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.purge()
+		}
+	}()
+}
+
+// purge deletes entries older than the retention period.
+func (s *AuditService) purge() {
+	cutoff := time.Now().Add(-s.cfg.AuditLogRetention)
+	result := database.DB.Where("created_at < ?", cutoff).Delete(&models.AuditLog{})
+	if result.Error != nil {
+		log.Printf("[Audit] failed to purge old entries: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[Audit] purged %d entries older than %s", result.RowsAffected, cutoff.Format(time.RFC3339))
+	}
+}
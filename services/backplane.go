@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Channel used to invalidate the public settings cache on every instance.
+const BackplaneChannelSettings = "eman_settings"
+
+// Messages kept per channel for instances catching up after a reconnect
+const backplaneRetention = 1000
+
+// Backplane fans messages out to every backend instance, including the one
+// that published them. seq numbers the messages of a channel when the
+// backplane is shared between instances, and is 0 otherwise.
+type Backplane interface {
+	Publish(channel string, payload []byte) error
+	Subscribe(channel string, handler func(seq uint64, payload []byte))
+}
+
+// NewBackplane returns the backplane selected by HUB_BACKPLANE.
+func NewBackplane(cfg *config.Config) Backplane {
+	if cfg.Backplane == "postgres" {
+		log.Printf("[Backplane] using Postgres LISTEN/NOTIFY")
+		return NewPostgresBackplane(cfg.DBDSN)
+	}
+	log.Printf("[Backplane] in-process only (set HUB_BACKPLANE=postgres when running several instances)")
+	return NewLocalBackplane()
+}
+
+// subscriptions holds the handlers registered per channel.
+type subscriptions struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(uint64, []byte)
+}
+
+func (s *subscriptions) add(channel string, handler func(uint64, []byte)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string][]func(uint64, []byte))
+	}
+	s.handlers[channel] = append(s.handlers[channel], handler)
+}
+
+func (s *subscriptions) dispatch(channel string, seq uint64, payload []byte) {
+	s.mu.RLock()
+	handlers := s.handlers[channel]
+	s.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(seq, payload)
+	}
+}
+
+func (s *subscriptions) channels() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	channels := make([]string, 0, len(s.handlers))
+	for channel := range s.handlers {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// LocalBackplane delivers within this process only; enough for a single instance.
+type LocalBackplane struct {
+	subs subscriptions
+}
+
+func NewLocalBackplane() *LocalBackplane {
+	return &LocalBackplane{}
+}
+
+func (b *LocalBackplane) Publish(channel string, payload []byte) error {
+	b.subs.dispatch(channel, 0, payload)
+	return nil
+}
+
+func (b *LocalBackplane) Subscribe(channel string, handler func(uint64, []byte)) {
+	b.subs.add(channel, handler)
+}
+
+// PostgresBackplane stores each message in backplane_messages under the
+// next number of its channel and wakes the other instances with pg_notify.
+// They read new rows in order, also after their LISTEN connection comes
+// back, so every instance delivers the same messages with the same numbers.
+type PostgresBackplane struct {
+	dsn  string
+	subs subscriptions
+
+	mu        sync.Mutex
+	interrupt context.CancelFunc // wakes the listener to LISTEN on new channels
+	lastSeq   map[string]uint64  // last message delivered per channel
+	published uint64
+}
+
+func NewPostgresBackplane(dsn string) *PostgresBackplane {
+	b := &PostgresBackplane{dsn: dsn, lastSeq: make(map[string]uint64)}
+	go b.listen()
+	return b
+}
+
+// Publish numbers and stores the message. The channel row stays locked
+// until commit, so messages become visible in the order of their numbers.
+func (b *PostgresBackplane) Publish(channel string, payload []byte) error {
+	var seq uint64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBackplaneChannel(tx, channel); err != nil {
+			return err
+		}
+		if err := tx.Raw("UPDATE backplane_channels SET last_seq = last_seq + 1 WHERE channel = ? RETURNING last_seq", channel).
+			Scan(&seq).Error; err != nil {
+			return err
+		}
+		message := models.BackplaneMessage{Channel: channel, Seq: seq, Payload: string(payload)}
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		return tx.Exec("SELECT pg_notify(?, ?)", channel, strconv.FormatUint(seq, 10)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.published++
+	prune := b.published%100 == 0
+	b.mu.Unlock()
+	if prune && seq > backplaneRetention {
+		database.DB.Where("channel = ? AND seq <= ?", channel, seq-backplaneRetention).Delete(&models.BackplaneMessage{})
+	}
+	return nil
+}
+
+func ensureBackplaneChannel(tx *gorm.DB, channel string) error {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.BackplaneChannel{Channel: channel, Epoch: hex.EncodeToString(buf)}).Error
+}
+
+// Position returns the epoch and last number of a channel, and starts
+// delivery after that number. Call it before Subscribe.
+func (b *PostgresBackplane) Position(channel string) (string, uint64, error) {
+	if err := ensureBackplaneChannel(database.DB, channel); err != nil {
+		return "", 0, err
+	}
+	var row models.BackplaneChannel
+	if err := database.DB.Where("channel = ?", channel).First(&row).Error; err != nil {
+		return "", 0, err
+	}
+	b.mu.Lock()
+	if _, ok := b.lastSeq[channel]; !ok {
+		b.lastSeq[channel] = row.LastSeq
+	}
+	seq := b.lastSeq[channel]
+	b.mu.Unlock()
+	return row.Epoch, seq, nil
+}
+
+func (b *PostgresBackplane) Subscribe(channel string, handler func(uint64, []byte)) {
+	if _, _, err := b.Position(channel); err != nil {
+		log.Printf("[Backplane] failed to read position of %s: %v", channel, err)
+	}
+	b.subs.add(channel, handler)
+	b.mu.Lock()
+	if b.interrupt != nil {
+		b.interrupt()
+	}
+	b.mu.Unlock()
+}
+
+// catchUp delivers the stored messages of channel not delivered yet. Gaps
+// left by pruning are passed on as they are; the hub asks its clients to
+// resync when it sees one.
+func (b *PostgresBackplane) catchUp(channel string) error {
+	b.mu.Lock()
+	last := b.lastSeq[channel]
+	b.mu.Unlock()
+
+	var messages []models.BackplaneMessage
+	if err := database.DB.Where("channel = ? AND seq > ?", channel, last).
+		Order("seq ASC").Find(&messages).Error; err != nil {
+		return err
+	}
+	for _, message := range messages {
+		b.subs.dispatch(channel, message.Seq, []byte(message.Payload))
+		b.mu.Lock()
+		b.lastSeq[channel] = message.Seq
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+func (b *PostgresBackplane) listen() {
+	backoff := time.Second
+	for {
+		err := b.listenOnce(func() { backoff = time.Second })
+		log.Printf("[Backplane] listener stopped: %v; reconnecting in %s", err, backoff)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// listenOnce runs one LISTEN connection until it fails. Every channel is
+// caught up right after its LISTEN, which covers whatever was published
+// while the connection was down.
+func (b *PostgresBackplane) listenOnce(connected func()) error {
+	conn, err := pgx.Connect(context.Background(), b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	connected()
+
+	listening := make(map[string]bool)
+	for {
+		// Armed before reading the channels so a concurrent Subscribe is never missed
+		ctx, cancel := context.WithCancel(context.Background())
+		b.mu.Lock()
+		b.interrupt = cancel
+		b.mu.Unlock()
+
+		for _, channel := range b.subs.channels() {
+			if listening[channel] {
+				continue
+			}
+			if _, err := conn.Exec(context.Background(), "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				cancel()
+				return err
+			}
+			listening[channel] = true
+			if err := b.catchUp(channel); err != nil {
+				cancel()
+				return err
+			}
+		}
+
+		notification, err := conn.WaitForNotification(ctx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				continue // a new subscription, LISTEN and wait again
+			}
+			return err
+		}
+		if err := b.catchUp(notification.Channel); err != nil {
+			return err
+		}
+	}
+}
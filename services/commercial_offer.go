@@ -0,0 +1,443 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+//go:embed fonts/DejaVuSans.ttf
+var offerFontRegular []byte
+
+//go:embed fonts/DejaVuSans-Bold.ttf
+var offerFontBold []byte
+
+const (
+	offerFontFamily   = "DejaVu"
+	offerMaxImageSize = 15 * 1024 * 1024
+	// Decoded size bound; a small compressed file can claim huge dimensions
+	offerMaxImagePixels = 25_000_000
+)
+
+// OfferContacts are the contact settings printed in the offer footer block.
+type OfferContacts struct {
+	Phone        string
+	Address      string
+	WorkingHours string
+}
+
+// CommercialOffer holds everything needed to render one offer PDF.
+type CommercialOffer struct {
+	Lang        string
+	Brand       string
+	EstateID    int
+	Estate      map[string]any
+	Contacts    OfferContacts
+	Calculation *PaymentCalculation
+}
+
+type CommercialOfferService struct {
+	client *http.Client
+}
+
+func NewCommercialOfferService() *CommercialOfferService {
+	return &CommercialOfferService{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+type offerLabels struct {
+	Title        string
+	Estate       string
+	Price        string
+	Area         string
+	Rooms        string
+	Floor        string
+	Address      string
+	Type         string
+	Category     string
+	Plan         string
+	PaymentPlan  string
+	FinalPrice   string
+	Discount     string
+	DownPayment  string
+	Financed     string
+	Term         string
+	Months       string
+	Interest     string
+	Monthly      string
+	TotalPayment string
+	Month        string
+	Payment      string
+	Principal    string
+	InterestCol  string
+	Balance      string
+	Contacts     string
+	Phone        string
+	WorkingHours string
+	Generated    string
+	Page         string
+}
+
+var offerLabelsByLang = map[string]offerLabels{
+	"ru": {
+		Title: "Коммерческое предложение", Estate: "Объект", Price: "Цена", Area: "Площадь, м²",
+		Rooms: "Комнат", Floor: "Этаж", Address: "Адрес", Type: "Тип", Category: "Категория",
+		Plan: "Планировка", PaymentPlan: "План оплаты", FinalPrice: "Цена со скидкой", Discount: "Скидка",
+		DownPayment: "Первоначальный взнос", Financed: "Сумма рассрочки", Term: "Срок", Months: "мес.",
+		Interest: "Ставка, % годовых", Monthly: "Ежемесячный платёж", TotalPayment: "Итого к оплате",
+		Month: "Месяц", Payment: "Платёж", Principal: "Основной долг", InterestCol: "Проценты", Balance: "Остаток",
+		Contacts: "Контакты", Phone: "Телефон", WorkingHours: "Время работы", Generated: "Сформировано", Page: "Стр.",
+	},
+	"uz": {
+		Title: "Tijorat taklifi", Estate: "Obyekt", Price: "Narx", Area: "Maydon, m²",
+		Rooms: "Xonalar", Floor: "Qavat", Address: "Manzil", Type: "Turi", Category: "Toifa",
+		Plan: "Reja", PaymentPlan: "To'lov rejasi", FinalPrice: "Chegirmali narx", Discount: "Chegirma",
+		DownPayment: "Boshlang'ich to'lov", Financed: "Bo'lib to'lash summasi", Term: "Muddat", Months: "oy",
+		Interest: "Yillik stavka, %", Monthly: "Oylik to'lov", TotalPayment: "Jami to'lov",
+		Month: "Oy", Payment: "To'lov", Principal: "Asosiy qarz", InterestCol: "Foizlar", Balance: "Qoldiq",
+		Contacts: "Kontaktlar", Phone: "Telefon", WorkingHours: "Ish vaqti", Generated: "Tuzilgan sana", Page: "Bet",
+	},
+	"en": {
+		Title: "Commercial offer", Estate: "Property", Price: "Price", Area: "Area, m²",
+		Rooms: "Rooms", Floor: "Floor", Address: "Address", Type: "Type", Category: "Category",
+		Plan: "Floor plan", PaymentPlan: "Payment plan", FinalPrice: "Discounted price", Discount: "Discount",
+		DownPayment: "Down payment", Financed: "Financed amount", Term: "Term", Months: "mo.",
+		Interest: "Interest, % p.a.", Monthly: "Monthly payment", TotalPayment: "Total payable",
+		Month: "Month", Payment: "Payment", Principal: "Principal", InterestCol: "Interest", Balance: "Balance",
+		Contacts: "Contacts", Phone: "Phone", WorkingHours: "Working hours", Generated: "Generated", Page: "Page",
+	},
+}
+
+// NormalizeOfferLang falls back to Russian for unknown languages.
+func NormalizeOfferLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if _, ok := offerLabelsByLang[lang]; ok {
+		return lang
+	}
+	return "ru"
+}
+
+// RenderPDF builds the branded offer document.
+func (s *CommercialOfferService) RenderPDF(offer CommercialOffer) ([]byte, error) {
+	lang := NormalizeOfferLang(offer.Lang)
+	labels := offerLabelsByLang[lang]
+
+	brand := strings.TrimSpace(offer.Brand)
+	if brand == "" {
+		brand = "EMAN RIVERSIDE"
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(offerFontFamily, "", offerFontRegular)
+	pdf.AddUTF8FontFromBytes(offerFontFamily, "B", offerFontBold)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 18)
+	pdf.SetTitle(fmt.Sprintf("%s #%d", labels.Title, offer.EstateID), true)
+	pdf.SetCreator(brand, true)
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(offerFontFamily, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%s · %s %d", brand, labels.Page, pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentWidth := pageWidth - left - right
+
+	// Header band
+	pdf.SetFillColor(24, 48, 40)
+	pdf.Rect(0, 0, pageWidth, 28, "F")
+	pdf.SetXY(left, 8)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont(offerFontFamily, "B", 18)
+	pdf.CellFormat(contentWidth/2, 8, brand, "", 0, "L", false, 0, "")
+	pdf.SetFont(offerFontFamily, "", 11)
+	pdf.CellFormat(contentWidth/2, 8, labels.Title, "", 1, "R", false, 0, "")
+	pdf.SetY(34)
+
+	// Estate title
+	title := estateHumanTitle(offer.Estate)
+	if title == "" {
+		title = fmt.Sprintf("%s #%d", labels.Estate, offer.EstateID)
+	}
+	pdf.SetTextColor(24, 48, 40)
+	pdf.SetFont(offerFontFamily, "B", 15)
+	pdf.MultiCell(contentWidth, 7, title, "", "L", false)
+	pdf.Ln(2)
+
+	// Estate parameters
+	rows := [][2]string{}
+	addRow := func(label, value string) {
+		if strings.TrimSpace(value) != "" {
+			rows = append(rows, [2]string{label, value})
+		}
+	}
+	addRow("ID", strconv.Itoa(offer.EstateID))
+	if price, ok := getNumberField(offer.Estate, "estate_price"); ok {
+		addRow(labels.Price, formatOfferAmount(price))
+	}
+	if area, ok := getNumberField(offer.Estate, "estate_area"); ok {
+		addRow(labels.Area, strconv.FormatFloat(area, 'f', -1, 64))
+	}
+	if rooms, ok := getNumberField(offer.Estate, "estate_rooms"); ok {
+		addRow(labels.Rooms, strconv.FormatFloat(rooms, 'f', -1, 64))
+	}
+	if floor, ok := getNumberField(offer.Estate, "estate_floor"); ok {
+		addRow(labels.Floor, strconv.FormatFloat(floor, 'f', -1, 64))
+	}
+	addRow(labels.Address, strings.TrimSpace(getStringField(offer.Estate, "address")))
+	addRow(labels.Type, strings.TrimSpace(getStringField(offer.Estate, "type")))
+	addRow(labels.Category, strings.TrimSpace(getStringField(offer.Estate, "category")))
+
+	pdf.SetFont(offerFontFamily, "", 10)
+	pdf.SetTextColor(40, 40, 40)
+	pdf.SetDrawColor(220, 220, 220)
+	for i, row := range rows {
+		fill := i%2 == 0
+		pdf.SetFillColor(244, 246, 245)
+		pdf.CellFormat(contentWidth*0.4, 7, row[0], "B", 0, "L", fill, 0, "")
+		pdf.CellFormat(contentWidth*0.6, 7, row[1], "B", 1, "L", fill, 0, "")
+	}
+	pdf.Ln(4)
+
+	// Floor plan image
+	if imageURL := estatePlanImageURL(offer.Estate); imageURL != "" {
+		if data, err := s.downloadImage(imageURL); err != nil {
+			log.Printf("[Offer] plan image for estate %d skipped: %v", offer.EstateID, err)
+		} else {
+			info := pdf.RegisterImageOptionsReader("plan", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(data))
+			if pdf.Ok() && info != nil && info.Width() > 0 {
+				maxW, maxH := contentWidth, 110.0
+				w, h := info.Width(), info.Height()
+				scale := math.Min(maxW/w, maxH/h)
+				w, h = w*scale, h*scale
+
+				pdf.SetFont(offerFontFamily, "B", 12)
+				pdf.SetTextColor(24, 48, 40)
+				pdf.CellFormat(contentWidth, 7, labels.Plan, "", 1, "L", false, 0, "")
+				if pdf.GetY()+h > 279 {
+					pdf.AddPage()
+				}
+				pdf.ImageOptions("plan", left+(contentWidth-w)/2, pdf.GetY(), w, h, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+				pdf.SetY(pdf.GetY() + h + 4)
+			}
+		}
+	}
+
+	// Payment plan
+	if calc := offer.Calculation; calc != nil {
+		pdf.SetFont(offerFontFamily, "B", 12)
+		pdf.SetTextColor(24, 48, 40)
+		pdf.CellFormat(contentWidth, 8, fmt.Sprintf("%s: %s", labels.PaymentPlan, calc.Plan), "", 1, "L", false, 0, "")
+
+		summary := [][2]string{
+			{labels.Price, formatOfferAmount(calc.Price)},
+		}
+		if calc.DiscountAmount > 0 {
+			summary = append(summary,
+				[2]string{labels.Discount, fmt.Sprintf("%s%% (%s)", formatPercent(calc.DiscountPercent), formatOfferAmount(calc.DiscountAmount))},
+				[2]string{labels.FinalPrice, formatOfferAmount(calc.FinalPrice)},
+			)
+		}
+		summary = append(summary,
+			[2]string{labels.DownPayment, fmt.Sprintf("%s (%s%%)", formatOfferAmount(calc.DownPayment), formatPercent(calc.DownPaymentPercent))},
+		)
+		if calc.TermMonths > 0 {
+			summary = append(summary,
+				[2]string{labels.Financed, formatOfferAmount(calc.FinancedAmount)},
+				[2]string{labels.Term, fmt.Sprintf("%d %s", calc.TermMonths, labels.Months)},
+				[2]string{labels.Interest, formatPercent(calc.InterestRate)},
+				[2]string{labels.Monthly, formatOfferAmount(calc.MonthlyPayment)},
+			)
+		}
+		summary = append(summary, [2]string{labels.TotalPayment, formatOfferAmount(calc.TotalPayment)})
+
+		pdf.SetFont(offerFontFamily, "", 10)
+		pdf.SetTextColor(40, 40, 40)
+		for _, row := range summary {
+			pdf.CellFormat(contentWidth*0.5, 6.5, row[0], "B", 0, "L", false, 0, "")
+			pdf.CellFormat(contentWidth*0.5, 6.5, row[1], "B", 1, "R", false, 0, "")
+		}
+		pdf.Ln(4)
+
+		if len(calc.Schedule) > 0 {
+			widths := []float64{contentWidth * 0.12, contentWidth * 0.22, contentWidth * 0.22, contentWidth * 0.2, contentWidth * 0.24}
+			header := []string{labels.Month, labels.Payment, labels.Principal, labels.InterestCol, labels.Balance}
+			drawHeader := func() {
+				pdf.SetFont(offerFontFamily, "B", 9)
+				pdf.SetFillColor(24, 48, 40)
+				pdf.SetTextColor(255, 255, 255)
+				for i, text := range header {
+					pdf.CellFormat(widths[i], 7, text, "", 0, "C", true, 0, "")
+				}
+				pdf.Ln(-1)
+				pdf.SetFont(offerFontFamily, "", 9)
+				pdf.SetTextColor(40, 40, 40)
+			}
+
+			drawHeader()
+			for i, row := range calc.Schedule {
+				if pdf.GetY()+6 > 279 {
+					pdf.AddPage()
+					drawHeader()
+				}
+				pdf.SetFillColor(244, 246, 245)
+				fill := i%2 == 1
+				pdf.CellFormat(widths[0], 6, strconv.Itoa(row.Month), "", 0, "C", fill, 0, "")
+				pdf.CellFormat(widths[1], 6, formatOfferAmount(row.Payment), "", 0, "R", fill, 0, "")
+				pdf.CellFormat(widths[2], 6, formatOfferAmount(row.Principal), "", 0, "R", fill, 0, "")
+				pdf.CellFormat(widths[3], 6, formatOfferAmount(row.Interest), "", 0, "R", fill, 0, "")
+				pdf.CellFormat(widths[4], 6, formatOfferAmount(row.Balance), "", 1, "R", fill, 0, "")
+			}
+			pdf.Ln(4)
+		}
+	}
+
+	// Contacts
+	if pdf.GetY()+30 > 279 {
+		pdf.AddPage()
+	}
+	pdf.SetFont(offerFontFamily, "B", 12)
+	pdf.SetTextColor(24, 48, 40)
+	pdf.CellFormat(contentWidth, 8, labels.Contacts, "", 1, "L", false, 0, "")
+	pdf.SetFont(offerFontFamily, "", 10)
+	pdf.SetTextColor(40, 40, 40)
+	for _, row := range [][2]string{
+		{labels.Phone, offer.Contacts.Phone},
+		{labels.Address, offer.Contacts.Address},
+		{labels.WorkingHours, offer.Contacts.WorkingHours},
+	} {
+		if strings.TrimSpace(row[1]) == "" {
+			continue
+		}
+		pdf.CellFormat(contentWidth*0.3, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.MultiCell(contentWidth*0.7, 6, row[1], "", "L", false)
+	}
+	pdf.Ln(2)
+	pdf.SetFont(offerFontFamily, "", 8)
+	pdf.SetTextColor(120, 120, 120)
+	pdf.CellFormat(contentWidth, 5, fmt.Sprintf("%s: %s", labels.Generated, time.Now().Format("02.01.2006")), "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("render offer pdf failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// downloadImage fetches a remote image and re-encodes it as PNG, which keeps
+// WebP/GIF plans printable since the PDF writer only accepts JPEG/PNG/GIF.
+func (s *CommercialOfferService) downloadImage(rawURL string) ([]byte, error) {
+	resp, err := s.client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, offerMaxImageSize))
+	if err != nil {
+		return nil, fmt.Errorf("read image failed: %w", err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image failed: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > offerMaxImagePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image failed: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// estatePlanImageURL finds the layout image in a Macro estate record. The feed
+// is not strictly typed, so both plain strings and lists of strings/objects are accepted.
+func estatePlanImageURL(item map[string]any) string {
+	for _, key := range []string{"plan", "plans", "estate_plan", "layout", "image", "images"} {
+		if url := firstImageURL(item[key]); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+func firstImageURL(v any) string {
+	switch value := v.(type) {
+	case string:
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "http://") || strings.HasPrefix(trimmed, "https://") {
+			return trimmed
+		}
+	case []any:
+		for _, entry := range value {
+			if url := firstImageURL(entry); url != "" {
+				return url
+			}
+		}
+	case map[string]any:
+		for _, key := range []string{"src", "url", "full", "big"} {
+			if url := firstImageURL(value[key]); url != "" {
+				return url
+			}
+		}
+	}
+	return ""
+}
+
+// formatOfferAmount renders money as "1 234 567" or "1 234 567.50".
+func formatOfferAmount(v float64) string {
+	v = roundMoney(v)
+	negative := v < 0
+	if negative {
+		v = -v
+	}
+
+	whole := int64(v)
+	cents := int64(math.Round((v - float64(whole)) * 100))
+	digits := strconv.FormatInt(whole, 10)
+
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	out := b.String()
+	if cents > 0 {
+		out += fmt.Sprintf(".%02d", cents)
+	}
+	if negative {
+		out = "-" + out
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testOfferCalculation(t *testing.T) *PaymentCalculation {
+	t.Helper()
+	plan := PaymentPlan{Title: "Рассрочка", MinDownPaymentPercent: 30, TermMonths: 12, InterestRate: 10}
+	calc, err := CalculatePayment(plan, PaymentCalculationInput{Price: 850_000_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calc
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// bombPNG is a tiny PNG whose header claims w×h pixels.
+func bombPNG(t *testing.T, w, h uint32) []byte {
+	t.Helper()
+	data := encodePNG(t, 1, 1)
+	ihdr := data[8+8 : 8+8+13] // after the signature and the chunk length and type
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func TestRenderOfferPDFInEveryLanguage(t *testing.T) {
+	plan := encodePNG(t, 40, 30)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(plan)
+	}))
+	defer server.Close()
+
+	service := NewCommercialOfferService()
+	estate := map[string]any{
+		"estate_price": 850_000_000.0,
+		"estate_area":  64.5,
+		"estate_rooms": 2.0,
+		"address":      "Ташкент, ул. Шота Руставели, 12",
+		"plan":         server.URL + "/plan.png",
+	}
+
+	documents := map[string][]byte{}
+	for _, lang := range []string{"ru", "uz", "en"} {
+		for _, calc := range []*PaymentCalculation{testOfferCalculation(t), nil} {
+			data, err := service.RenderPDF(CommercialOffer{
+				Lang:        lang,
+				EstateID:    42,
+				Estate:      estate,
+				Contacts:    OfferContacts{Phone: "+998 71 000 00 00", Address: "Ташкент"},
+				Calculation: calc,
+			})
+			if err != nil {
+				t.Fatalf("%s (calculation %v): %v", lang, calc != nil, err)
+			}
+			if !bytes.HasPrefix(data, []byte("%PDF")) {
+				t.Fatalf("%s: output is not a PDF", lang)
+			}
+			if calc != nil {
+				documents[lang] = data
+			}
+		}
+	}
+	if bytes.Equal(documents["ru"], documents["uz"]) || bytes.Equal(documents["ru"], documents["en"]) {
+		t.Fatal("languages render the same document")
+	}
+}
+
+func TestNormalizeOfferLang(t *testing.T) {
+	for lang, want := range map[string]string{"EN": "en", " uz ": "uz", "de": "ru", "": "ru"} {
+		if got := NormalizeOfferLang(lang); got != want {
+			t.Errorf("NormalizeOfferLang(%q) = %q, want %q", lang, got, want)
+		}
+	}
+}
+
+func TestDownloadImageRejectsDecompressionBomb(t *testing.T) {
+	images := map[string][]byte{
+		"/plan.png": encodePNG(t, 40, 30),
+		"/bomb.png": bombPNG(t, 100_000, 100_000),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(images[r.URL.Path])
+	}))
+	defer server.Close()
+
+	service := NewCommercialOfferService()
+	if _, err := service.downloadImage(server.URL + "/plan.png"); err != nil {
+		t.Fatalf("plan image: %v", err)
+	}
+	_, err := service.downloadImage(server.URL + "/bomb.png")
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("got %v, want the oversized image refused", err)
+	}
+}
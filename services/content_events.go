@@ -0,0 +1,128 @@
+package services
+
+import (
+	"bytes"
+	"eman-backend/config"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	ws "eman-backend/websocket"
+)
+
+// Entity types carried by content.updated events and media references.
+const (
+	ContentGalleryItem = "gallery_item"
+	ContentProject     = "project"
+	ContentChallenge   = "challenge"
+	ContentMapIcon     = "map_icon"
+	ContentMapIconType = "map_icon_type"
+	ContentSetting     = "site_setting"
+)
+
+// Actions carried by content.updated events.
+const (
+	ContentCreated   = "created"
+	ContentUpdated   = "updated"
+	ContentDeleted   = "deleted"
+	ContentReordered = "reordered"
+	ContentReset     = "reset"
+)
+
+// ContentEvent tells the public site which entity changed. Settings use the
+// key as id; bulk edits list every affected id in EntityIDs.
+type ContentEvent struct {
+	EntityType string   `json:"entity_type"`
+	EntityID   string   `json:"entity_id,omitempty"`
+	EntityIDs  []string `json:"entity_ids,omitempty"`
+	Action     string   `json:"action"`
+	At         int64    `json:"at"`
+}
+
+// ContentEventService announces admin edits to connected visitors and,
+// when REVALIDATE_URL is set, to the frontend's on-demand revalidation hook.
+type ContentEventService struct {
+	revalidateURL    string
+	revalidateSecret string
+	client           *http.Client
+}
+
+func NewContentEventService(cfg *config.Config) *ContentEventService {
+	return &ContentEventService{
+		revalidateURL:    cfg.RevalidateURL,
+		revalidateSecret: cfg.RevalidateSecret,
+		client:           &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// EmitID is Emit for entities with a numeric primary key.
+func (s *ContentEventService) EmitID(entityType, action string, id uint) {
+	s.Emit(entityType, action, strconv.FormatUint(uint64(id), 10))
+}
+
+// Emit publishes a content.updated event. Settings go to public.settings,
+// everything else to public.content.
+func (s *ContentEventService) Emit(entityType, action string, ids ...string) {
+	if s == nil {
+		return
+	}
+
+	event := ContentEvent{EntityType: entityType, Action: action, At: time.Now().Unix()}
+	if len(ids) == 1 {
+		event.EntityID = ids[0]
+	} else {
+		event.EntityIDs = ids
+	}
+
+	topic := ws.TopicPublicContent
+	if entityType == ContentSetting {
+		topic = ws.TopicPublicSettings
+	}
+	ws.GetHub().Publish(topic, "content.updated", event)
+
+	// Only the instance that made the edit calls the hook
+	if s.revalidateURL != "" {
+		go s.revalidate(event)
+	}
+}
+
+// revalidate posts the event with cache tags the frontend can pass to revalidateTag.
+func (s *ContentEventService) revalidate(event ContentEvent) {
+	tags := []string{event.EntityType}
+	if event.EntityID != "" {
+		tags = append(tags, event.EntityType+":"+event.EntityID)
+	}
+	for _, id := range event.EntityIDs {
+		tags = append(tags, event.EntityType+":"+id)
+	}
+
+	body, err := json.Marshal(struct {
+		ContentEvent
+		Tags []string `json:"tags"`
+	}{event, tags})
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.revalidateURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[Revalidate] invalid REVALIDATE_URL: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.revalidateSecret != "" {
+		req.Header.Set("X-Revalidate-Secret", s.revalidateSecret)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("[Revalidate] %s %s failed: %v", event.EntityType, event.Action, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("[Revalidate] %s %s: hook returned %d", event.EntityType, event.Action, resp.StatusCode)
+	}
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type CurrencyService struct {
+	cfg    *config.Config
+	client *http.Client
+	mu     sync.RWMutex
+	rates  map[string]float64
+}
+
+func NewCurrencyService(cfg *config.Config) *CurrencyService {
+	service := &CurrencyService{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 15 * time.Second},
+		rates:  make(map[string]float64),
+	}
+	if err := service.Reload(); err != nil {
+		log.Printf("[Currency] failed to load exchange rates: %v", err)
+	}
+	service.startImporter()
+	return service
+}
+
+// BaseCurrency is the currency Macro prices are quoted in.
+func (s *CurrencyService) BaseCurrency() string {
+	return s.cfg.BaseCurrency
+}
+
+// NormalizeCurrency upper-cases and trims a currency code.
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Reload refreshes the in-memory rate table from the database.
+func (s *CurrencyService) Reload() error {
+	var items []models.ExchangeRate
+	if err := database.DB.Find(&items).Error; err != nil {
+		return err
+	}
+
+	rates := make(map[string]float64, len(items))
+	for _, item := range items {
+		if item.Rate > 0 {
+			rates[NormalizeCurrency(item.Currency)] = item.Rate
+		}
+	}
+
+	s.mu.Lock()
+	s.rates = rates
+	s.mu.Unlock()
+	return nil
+}
+
+// Rate returns how many base units one unit of code costs.
+func (s *CurrencyService) Rate(code string) (float64, bool) {
+	code = NormalizeCurrency(code)
+	if code == "" || code == s.cfg.BaseCurrency {
+		return 1, true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rate, ok := s.rates[code]
+	return rate, ok && rate > 0
+}
+
+// SetRate stores a manually entered rate.
+func (s *CurrencyService) SetRate(code string, rate float64) (*models.ExchangeRate, error) {
+	code = NormalizeCurrency(code)
+	if code == "" || code == s.cfg.BaseCurrency {
+		return nil, fmt.Errorf("invalid currency")
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+
+	item := models.ExchangeRate{Currency: code, Rate: rate, Source: models.RateSourceManual}
+	if err := upsertRate(&item); err != nil {
+		return nil, err
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// DeleteRate removes a currency from the store.
+func (s *CurrencyService) DeleteRate(code string) (bool, error) {
+	result := database.DB.Where("currency = ?", NormalizeCurrency(code)).Delete(&models.ExchangeRate{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if err := s.Reload(); err != nil {
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func upsertRate(item *models.ExchangeRate) error {
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).Create(item).Error
+}
+
+func (s *CurrencyService) startImporter() {
+	if strings.TrimSpace(s.cfg.ExchangeRatesURL) == "" {
+		return
+	}
+
+	go func() {
+		if _, err := s.Import(); err != nil {
+			log.Printf("[Currency] initial import failed: %v", err)
+		}
+
+		ticker := time.NewTicker(s.cfg.ExchangeRatesSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := s.Import(); err != nil {
+				log.Printf("[Currency] periodic import failed, keeping old rates: %v", err)
+			}
+		}
+	}()
+}
+
+// Import pulls rates from EXCHANGE_RATES_URL. Manually entered rates are left
+// untouched so an admin override survives the next sync; delete the manual
+// rate to hand the currency back to the importer.
+func (s *CurrencyService) Import() (int, error) {
+	rates, err := s.fetchRates()
+	if err != nil {
+		return 0, err
+	}
+
+	var manual []models.ExchangeRate
+	if err := database.DB.Where("source = ?", models.RateSourceManual).Find(&manual).Error; err != nil {
+		return 0, err
+	}
+	skip := make(map[string]bool, len(manual))
+	for _, item := range manual {
+		skip[NormalizeCurrency(item.Currency)] = true
+	}
+
+	imported := 0
+	for code, rate := range rates {
+		if code == s.cfg.BaseCurrency || skip[code] || rate <= 0 {
+			continue
+		}
+		item := models.ExchangeRate{Currency: code, Rate: rate, Source: models.RateSourceImport}
+		if err := upsertRate(&item); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	if err := s.Reload(); err != nil {
+		return imported, err
+	}
+	log.Printf("[Currency] imported %d exchange rates", imported)
+	return imported, nil
+}
+
+// fetchRates reads and decodes the feed at EXCHANGE_RATES_URL.
+func (s *CurrencyService) fetchRates() (map[string]float64, error) {
+	source := strings.TrimSpace(s.cfg.ExchangeRatesURL)
+	if source == "" {
+		return nil, fmt.Errorf("EXCHANGE_RATES_URL is not configured")
+	}
+
+	raw, err := s.readSource(source)
+	if err != nil {
+		return nil, err
+	}
+	return parseExchangeRates(raw)
+}
+
+// readSource accepts http(s) URLs, file:// URLs and plain paths, so a local
+// JSON file can stand in for the real feed.
+func (s *CurrencyService) readSource(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := s.client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	return os.ReadFile(strings.TrimPrefix(source, "file://"))
+}
+
+// parseExchangeRates understands two shapes:
+//   - {"USD": 12650.5, "EUR": "13700"}
+//   - [{"Ccy": "USD", "Rate": "12650.50", "Nominal": "1"}, ...] (CBU format)
+func parseExchangeRates(raw []byte) (map[string]float64, error) {
+	rates := make(map[string]float64)
+
+	var flat map[string]any
+	if err := json.Unmarshal(raw, &flat); err == nil {
+		for code, value := range flat {
+			if rate, ok := toNumber(value); ok {
+				rates[NormalizeCurrency(code)] = rate
+			}
+		}
+		return rates, nil
+	}
+
+	var list []map[string]any
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode exchange rates failed: %w", err)
+	}
+	for _, item := range list {
+		code := NormalizeCurrency(firstStringField(item, "Ccy", "ccy", "code", "currency"))
+		rate, ok := firstNumberField(item, "Rate", "rate")
+		if code == "" || !ok {
+			continue
+		}
+		if nominal, ok := firstNumberField(item, "Nominal", "nominal"); ok && nominal > 0 {
+			rate = rate / nominal
+		}
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+func firstStringField(item map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v := strings.TrimSpace(getStringField(item, key)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNumberField(item map[string]any, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		if v, ok := getNumberField(item, key); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// estatePriceFields are the Macro feed fields that hold money amounts.
+var estatePriceFields = []string{"estate_price", "estate_price_m2"}
+
+// ConvertEstatePrices returns copies of the estates with price fields converted
+// from the base currency into code. The original amount is kept alongside.
+func (s *CurrencyService) ConvertEstatePrices(estates []map[string]any, code string) ([]map[string]any, error) {
+	code = NormalizeCurrency(code)
+	rate, ok := s.Rate(code)
+	if !ok {
+		return nil, fmt.Errorf("unknown currency: %s", code)
+	}
+
+	out := make([]map[string]any, 0, len(estates))
+	for _, item := range estates {
+		converted := make(map[string]any, len(item)+len(estatePriceFields)+2)
+		for k, v := range item {
+			converted[k] = v
+		}
+		for _, field := range estatePriceFields {
+			if price, ok := getNumberField(item, field); ok {
+				converted[field+"_original"] = price
+				converted[field] = roundMoney(price / rate)
+			}
+		}
+		converted["currency"] = code
+		converted["original_currency"] = s.cfg.BaseCurrency
+		out = append(out, converted)
+	}
+	return out, nil
+}
+
+// ToBase converts an amount in code into the base currency, formatted for a
+// Macro filter query parameter.
+func (s *CurrencyService) ToBase(amount string, code string) (string, error) {
+	rate, ok := s.Rate(code)
+	if !ok {
+		return "", fmt.Errorf("unknown currency: %s", NormalizeCurrency(code))
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return "", fmt.Errorf("invalid amount: %q", amount)
+	}
+	return strconv.FormatFloat(n*rate, 'f', -1, 64), nil
+}
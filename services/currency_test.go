@@ -0,0 +1,91 @@
+package services
+
+import (
+	"eman-backend/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const cbuFeed = `[
+	{"Ccy": "USD", "Rate": "12650.50", "Nominal": "1"},
+	{"Ccy": "JPY", "Rate": "8500", "Nominal": "100"},
+	{"Ccy": "XXX", "Rate": "n/a"}
+]`
+
+func testCurrencyService(url string) *CurrencyService {
+	return &CurrencyService{
+		cfg:    &config.Config{BaseCurrency: "UZS", ExchangeRatesURL: url},
+		client: http.DefaultClient,
+		rates:  map[string]float64{"USD": 12650.5},
+	}
+}
+
+func TestFetchRatesFromHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cbuFeed))
+	}))
+	defer server.Close()
+
+	rates, err := testCurrencyService(server.URL).fetchRates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates["USD"] != 12650.5 || rates["JPY"] != 85 {
+		t.Fatalf("unexpected rates: %v", rates)
+	}
+	if _, ok := rates["XXX"]; ok {
+		t.Fatal("unparseable rate was imported")
+	}
+}
+
+func TestFetchRatesFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if _, err := testCurrencyService(server.URL).fetchRates(); err == nil {
+		t.Fatal("error status was accepted")
+	}
+	if _, err := testCurrencyService("").fetchRates(); err == nil {
+		t.Fatal("empty source was accepted")
+	}
+}
+
+func TestFetchRatesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"usd": 12600, "EUR": "13700.25"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rates, err := testCurrencyService("file://" + path).fetchRates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates["USD"] != 12600 || rates["EUR"] != 13700.25 {
+		t.Fatalf("unexpected rates: %v", rates)
+	}
+}
+
+func TestToBase(t *testing.T) {
+	service := testCurrencyService("")
+
+	got, err := service.ToBase(" 100 ", "usd")
+	if err != nil || got != "1265050" {
+		t.Fatalf("ToBase(100 USD) = %q, %v", got, err)
+	}
+	if got, err := service.ToBase("5000", "UZS"); err != nil || got != "5000" {
+		t.Fatalf("ToBase(5000 UZS) = %q, %v", got, err)
+	}
+	for _, amount := range []string{"", "abc", "-1", "NaN", "Inf"} {
+		if _, err := service.ToBase(amount, "USD"); err == nil {
+			t.Errorf("ToBase(%q) was accepted", amount)
+		}
+	}
+	if _, err := service.ToBase("100", "EUR"); err == nil {
+		t.Error("unknown currency was accepted")
+	}
+}
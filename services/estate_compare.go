@@ -0,0 +1,71 @@
+package services
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// EstateFieldComparison is one row of the side-by-side comparison table.
+type EstateFieldComparison struct {
+	Key     string `json:"key"`
+	Values  []any  `json:"values"`
+	Differs bool   `json:"differs"`
+}
+
+// comparePriorityFields are shown first, in this order; the rest follow alphabetically.
+var comparePriorityFields = []string{
+	"title",
+	"estate_price",
+	"estate_price_m2",
+	"estate_area",
+	"estate_rooms",
+	"estate_floor",
+	"address",
+	"type",
+	"category",
+	"activity",
+}
+
+// CompareEstates lines the estates up field by field and flags the fields
+// whose values are not identical across all of them.
+func CompareEstates(estates []map[string]any) []EstateFieldComparison {
+	keys := make(map[string]bool)
+	for _, item := range estates {
+		for key := range item {
+			if key != "id" {
+				keys[key] = true
+			}
+		}
+	}
+
+	ordered := make([]string, 0, len(keys))
+	for _, key := range comparePriorityFields {
+		if keys[key] {
+			ordered = append(ordered, key)
+			delete(keys, key)
+		}
+	}
+	rest := make([]string, 0, len(keys))
+	for key := range keys {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	ordered = append(ordered, rest...)
+
+	rows := make([]EstateFieldComparison, 0, len(ordered))
+	for _, key := range ordered {
+		row := EstateFieldComparison{Key: key, Values: make([]any, len(estates))}
+		var first string
+		for i, item := range estates {
+			row.Values[i] = item[key]
+			encoded, _ := json.Marshal(item[key])
+			if i == 0 {
+				first = string(encoded)
+			} else if string(encoded) != first {
+				row.Differs = true
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
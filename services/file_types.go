@@ -0,0 +1,245 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Enough of a file to recognise its format
+const sniffLength = 512
+
+var (
+	ErrContentMismatch = errors.New("file content does not match its extension")
+	ErrContentBlocked  = errors.New("markup files are not allowed")
+)
+
+// Formats a browser would render as a page from our domain
+var blockedExtensions = map[string]bool{
+	".svg":   true,
+	".svgz":  true,
+	".html":  true,
+	".htm":   true,
+	".xhtml": true,
+	".xml":   true,
+	".js":    true,
+	".mjs":   true,
+}
+
+// ContentTypes is the Content-Type stored for each allowed extension. The
+// client's Content-Type is never trusted for what gets served.
+var ContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".txt":  "text/plain; charset=utf-8",
+	".rtf":  "application/rtf",
+	".csv":  "text/csv; charset=utf-8",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".zip":  "application/zip",
+	".rar":  "application/vnd.rar",
+	".7z":   "application/x-7z-compressed",
+}
+
+// Documents and archives are downloaded rather than opened in the browser
+var attachmentExtensions = map[string]bool{
+	".pdf":  true,
+	".doc":  true,
+	".docx": true,
+	".xls":  true,
+	".xlsx": true,
+	".ppt":  true,
+	".pptx": true,
+	".txt":  true,
+	".rtf":  true,
+	".csv":  true,
+	".odt":  true,
+	".ods":  true,
+	".odp":  true,
+	".zip":  true,
+	".rar":  true,
+	".7z":   true,
+}
+
+// ServeAsAttachment reports whether key should be sent with
+// Content-Disposition: attachment.
+func ServeAsAttachment(key string) bool {
+	return attachmentExtensions[strings.ToLower(path.Ext(key))]
+}
+
+func hasPrefix(signatures ...string) func([]byte) bool {
+	return func(header []byte) bool {
+		for _, signature := range signatures {
+			if bytes.HasPrefix(header, []byte(signature)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isISOMedia matches MP4-family files by their leading box.
+func isISOMedia(boxes ...string) func([]byte) bool {
+	return func(header []byte) bool {
+		if len(header) < 8 {
+			return false
+		}
+		for _, box := range boxes {
+			if string(header[4:8]) == box {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func isRIFF(format string) func([]byte) bool {
+	return func(header []byte) bool {
+		return len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == format
+	}
+}
+
+// isMPEGAudio matches an ID3 tag or a frame header whose second byte,
+// masked, equals sync.
+func isMPEGAudio(mask, sync byte) func([]byte) bool {
+	return func(header []byte) bool {
+		if bytes.HasPrefix(header, []byte("ID3")) {
+			return true
+		}
+		return len(header) >= 2 && header[0] == 0xFF && header[1]&mask == sync
+	}
+}
+
+func isPlainText(header []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(header), "text/plain")
+}
+
+var (
+	zipSignature = hasPrefix("PK\x03\x04", "PK\x05\x06", "PK\x07\x08")
+	oleSignature = hasPrefix("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
+)
+
+// fileSignatures recognise each allowed extension by its leading bytes
+var fileSignatures = map[string]func([]byte) bool{
+	".jpg":  hasPrefix("\xFF\xD8\xFF"),
+	".jpeg": hasPrefix("\xFF\xD8\xFF"),
+	".png":  hasPrefix("\x89PNG\r\n\x1A\n"),
+	".gif":  hasPrefix("GIF87a", "GIF89a"),
+	".webp": isRIFF("WEBP"),
+	".bmp":  hasPrefix("BM"),
+	".tif":  hasPrefix("II*\x00", "MM\x00*"),
+	".tiff": hasPrefix("II*\x00", "MM\x00*"),
+	".mp4":  isISOMedia("ftyp"),
+	".webm": hasPrefix("\x1A\x45\xDF\xA3"),
+	".mov":  isISOMedia("ftyp", "moov", "mdat", "wide", "free", "skip", "pnot"),
+	".mp3":  isMPEGAudio(0xE0, 0xE0),
+	".wav":  isRIFF("WAVE"),
+	".ogg":  hasPrefix("OggS"),
+	".m4a":  isISOMedia("ftyp"),
+	".aac":  isMPEGAudio(0xF6, 0xF0),
+	".pdf":  hasPrefix("%PDF-"),
+	".doc":  oleSignature,
+	".docx": zipSignature,
+	".xls":  oleSignature,
+	".xlsx": zipSignature,
+	".ppt":  oleSignature,
+	".pptx": zipSignature,
+	".txt":  isPlainText,
+	".rtf":  hasPrefix("{\\rtf"),
+	".csv":  isPlainText,
+	".odt":  zipSignature,
+	".ods":  zipSignature,
+	".odp":  zipSignature,
+	".zip":  zipSignature,
+	".rar":  hasPrefix("Rar!\x1A\x07"),
+	".7z":   hasPrefix("7z\xBC\xAF\x27\x1C"),
+}
+
+// Tags that make a text file dangerous if a browser ever renders it
+var markupTags = [][]byte{
+	[]byte("<svg"),
+	[]byte("<html"),
+	[]byte("<!doctype html"),
+	[]byte("<script"),
+	[]byte("<iframe"),
+}
+
+// looksLikeMarkup reports whether a file starts out as HTML, XML or SVG.
+// Text formats are also searched for markup anywhere in the header.
+func looksLikeMarkup(header []byte, text bool) bool {
+	switch normalizeContentType(http.DetectContentType(header)) {
+	case "text/html", "text/xml":
+		return true
+	}
+	if !text {
+		return false
+	}
+	lower := bytes.ToLower(header)
+	for _, tag := range markupTags {
+		if bytes.Contains(lower, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExtension rejects extensions that are not on the allow-list.
+func checkExtension(ext string) error {
+	switch {
+	case blockedExtensions[ext]:
+		return ErrContentBlocked
+	case ext == "":
+		return fmt.Errorf("file type not allowed")
+	case !AllowedExtensions[ext]:
+		return fmt.Errorf("file type not allowed: %s", ext)
+	}
+	return nil
+}
+
+// checkContent verifies that header, the start of the file, really is the
+// format its extension claims.
+func checkContent(ext string, header []byte) error {
+	if err := checkExtension(ext); err != nil {
+		return err
+	}
+	matches, ok := fileSignatures[ext]
+	if !ok {
+		return fmt.Errorf("file type not allowed: %s", ext)
+	}
+	text := ext == ".txt" || ext == ".csv" || ext == ".rtf"
+	if looksLikeMarkup(header, text) {
+		return ErrContentBlocked
+	}
+	if len(header) == 0 && text {
+		return nil
+	}
+	if !matches(header) {
+		return fmt.Errorf("%w: %s", ErrContentMismatch, ext)
+	}
+	return nil
+}
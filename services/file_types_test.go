@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckContent(t *testing.T) {
+	cases := []struct {
+		ext    string
+		header string
+		want   error // nil, ErrContentMismatch or ErrContentBlocked
+	}{
+		{".jpg", "\xFF\xD8\xFF\xE0\x00\x10JFIF", nil},
+		{".png", "\x89PNG\r\n\x1A\n\x00\x00", nil},
+		{".gif", "GIF89a\x01\x00", nil},
+		{".webp", "RIFF\x10\x00\x00\x00WEBPVP8 ", nil},
+		{".tif", "II*\x00\x08\x00\x00\x00", nil},
+		{".tiff", "MM\x00*\x00\x00\x00\x08", nil},
+		{".mp4", "\x00\x00\x00\x18ftypmp42", nil},
+		{".mov", "\x00\x00\x00\x08wide", nil},
+		{".mp3", "ID3\x04\x00", nil},
+		{".mp3", "\xFF\xFB\x90\x00", nil},
+		{".wav", "RIFF\x10\x00\x00\x00WAVEfmt ", nil},
+		{".pdf", "%PDF-1.7\n", nil},
+		{".docx", "PK\x03\x04\x14\x00", nil},
+		{".doc", "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1", nil},
+		{".7z", "7z\xBC\xAF\x27\x1C\x00\x04", nil},
+		{".txt", "plain notes\n", nil},
+		{".txt", "", nil},
+
+		{".jpg", "\x89PNG\r\n\x1A\n\x00\x00", ErrContentMismatch},
+		{".png", "\xFF\xD8\xFF\xE0", ErrContentMismatch},
+		{".webp", "RIFF\x10\x00\x00\x00WAVEfmt ", ErrContentMismatch},
+		{".pdf", "PK\x03\x04", ErrContentMismatch},
+		{".mp4", "\x00\x00", ErrContentMismatch},
+
+		{".svg", "<svg xmlns=\"http://www.w3.org/2000/svg\"/>", ErrContentBlocked},
+		{".jpg", "<!DOCTYPE html><html>", ErrContentBlocked},
+		{".txt", "hello <script>alert(1)</script>", ErrContentBlocked},
+		{".csv", "a,b\n<IFRAME src=x>", ErrContentBlocked},
+	}
+	for _, tc := range cases {
+		err := checkContent(tc.ext, []byte(tc.header))
+		switch {
+		case tc.want == nil && err != nil:
+			t.Errorf("%s %q: unexpected error %v", tc.ext, tc.header, err)
+		case tc.want != nil && !errors.Is(err, tc.want):
+			t.Errorf("%s %q: got %v, want %v", tc.ext, tc.header, err, tc.want)
+		}
+	}
+}
+
+func TestCheckExtension(t *testing.T) {
+	if err := checkExtension(".exe"); err == nil {
+		t.Error(".exe allowed")
+	}
+	if err := checkExtension(""); err == nil {
+		t.Error("missing extension allowed")
+	}
+	if err := checkExtension(".html"); !errors.Is(err, ErrContentBlocked) {
+		t.Errorf(".html: got %v", err)
+	}
+}
+
+func TestServeAsAttachment(t *testing.T) {
+	if !ServeAsAttachment("docs/Price.PDF") {
+		t.Error("pdf served inline")
+	}
+	if ServeAsAttachment("2025/01/photo.webp") {
+		t.Error("image served as attachment")
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// Metadata segments read ahead of a JPEG's image data, at most
+const maxJPEGHeader = 4 * 1024 * 1024
+
+// readJPEGHeader reads the segments of a JPEG up to the start of its image
+// data, where the EXIF segment is, however large the segments before it
+// are. The bytes read are returned even on error, so the caller can put
+// them back in front of the rest of the stream.
+func readJPEGHeader(r io.Reader) ([]byte, error) {
+	var head []byte
+	read := func(n int) ([]byte, error) {
+		start := len(head)
+		head = append(head, make([]byte, n)...)
+		got, err := io.ReadFull(r, head[start:])
+		head = head[:start+got]
+		return head[start:], err
+	}
+
+	soi, err := read(2)
+	if err != nil || !bytes.Equal(soi, []byte{0xFF, 0xD8}) {
+		return head, err
+	}
+	for len(head) < maxJPEGHeader {
+		prefix, err := read(1)
+		if err != nil || prefix[0] != 0xFF {
+			return head, err
+		}
+		marker, err := read(1)
+		for err == nil && marker[0] == 0xFF {
+			marker, err = read(1) // fill byte
+		}
+		if err != nil || marker[0] == 0xDA || marker[0] == 0xD9 {
+			return head, err
+		}
+		size, err := read(2)
+		if err != nil {
+			return head, err
+		}
+		length := int(binary.BigEndian.Uint16(size))
+		if length < 2 || len(head)+length-2 > maxJPEGHeader {
+			return head, nil
+		}
+		if _, err := read(length - 2); err != nil {
+			return head, err
+		}
+	}
+	return head, nil
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG whose
+// segments up to the image data are in data, or 1 when there is none.
+func jpegOrientation(data []byte) int {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return 1
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++ // fill byte
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return 1 // image data starts, no EXIF before it
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag from the first IFD of an EXIF
+// TIFF structure.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// applyOrientation turns img upright according to an EXIF orientation, since
+// the orientation tag itself is dropped on re-encoding.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			s := y*src.Stride + x*4
+			d := dy*dst.Stride + dx*4
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
+
+// VP8X flags announcing EXIF and XMP chunks
+const webpMetadataFlags = 0x08 | 0x04
+
+// stripWebPMetadata drops the EXIF and XMP chunks of a WebP file, which
+// may carry camera details and GPS position. Image data is left untouched.
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("invalid webp file")
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("invalid webp file")
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2
+		if end > len(data) {
+			// An odd-sized last chunk may lack its padding byte
+			if pos+8+size != len(data) {
+				return nil, fmt.Errorf("invalid webp file")
+			}
+			end = len(data)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		default:
+			start := len(out)
+			out = append(out, data[pos:end]...)
+			if fourCC == "VP8X" && size > 0 {
+				out[start+8] &^= webpMetadataFlags
+			}
+		}
+		pos = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifTIFF builds a TIFF structure whose first IFD holds only an
+// Orientation tag.
+func exifTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	buf := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(buf, order, uint16(42))
+	binary.Write(buf, order, uint32(8))
+	binary.Write(buf, order, uint16(1))
+	binary.Write(buf, order, uint16(0x0112)) // Orientation
+	binary.Write(buf, order, uint16(3))      // SHORT
+	binary.Write(buf, order, uint32(1))
+	binary.Write(buf, order, orientation)
+	binary.Write(buf, order, uint16(0))
+	binary.Write(buf, order, uint32(0)) // no next IFD
+	return buf.Bytes()
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG encodes a w×h JPEG and inserts segments right after its SOI.
+func testJPEG(t *testing.T, w, h int, segments ...[]byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	out := append([]byte{}, buf.Bytes()[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		if got := exifOrientation(exifTIFF(order, 6)); got != 6 {
+			t.Errorf("%v: got %d, want 6", order, got)
+		}
+	}
+	if got := exifOrientation(exifTIFF(binary.LittleEndian, 9)); got != 1 {
+		t.Errorf("out of range orientation: got %d, want 1", got)
+	}
+	if got := exifOrientation([]byte("II*\x00\xFF\xFF\xFF\xFF")); got != 1 {
+		t.Errorf("IFD past the end: got %d, want 1", got)
+	}
+}
+
+func TestJPEGOrientationAfterLargeSegments(t *testing.T) {
+	// ICC profiles and XMP can push EXIF well past the first 64KB
+	padding := bytes.Repeat([]byte{'x'}, 60000)
+	exif := append([]byte("Exif\x00\x00"), exifTIFF(binary.BigEndian, 6)...)
+	data := testJPEG(t, 40, 20, jpegSegment(0xE2, padding), jpegSegment(0xE2, padding), jpegSegment(0xE1, exif))
+
+	reader := bytes.NewReader(data)
+	head, err := readJPEGHeader(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(head) <= imageHeaderPeek {
+		t.Fatalf("header of %d bytes does not reach past the old peek", len(head))
+	}
+	if got := jpegOrientation(head); got != 6 {
+		t.Fatalf("got orientation %d, want 6", got)
+	}
+	if got := jpegOrientation(head[:imageHeaderPeek]); got != 1 {
+		t.Fatalf("truncated header: got %d, want 1", got)
+	}
+
+	// The header and the rest of the stream make up the whole file again
+	rest := make([]byte, reader.Len())
+	reader.Read(rest)
+	if !bytes.Equal(append(head, rest...), data) {
+		t.Fatal("header and rest do not add up to the file")
+	}
+}
+
+func TestReadJPEGHeaderStopsAtNonJPEG(t *testing.T) {
+	head, err := readJPEGHeader(bytes.NewReader([]byte("\x89PNG\r\n\x1A\n")))
+	if err != nil || string(head) != "\x89P" {
+		t.Fatalf("got %q, %v", head, err)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+
+	rotated := applyOrientation(img, 6)
+	if b := rotated.Bounds(); b.Dx() != 2 || b.Dy() != 3 {
+		t.Fatalf("rotated to %v", b)
+	}
+	// The top-left pixel ends up top-right after a clockwise turn
+	if r, _, _, _ := rotated.At(1, 0).RGBA(); r != 0xFFFF {
+		t.Fatal("pixel not moved to the top-right corner")
+	}
+	if applyOrientation(img, 1) != image.Image(img) {
+		t.Fatal("upright image was copied")
+	}
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	chunk := func(fourCC string, payload []byte) []byte {
+		out := append([]byte(fourCC), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(payload)))
+		out = append(out, payload...)
+		if len(payload)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", []byte{webpMetadataFlags | 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0})...)
+	body = append(body, chunk("VP8L", []byte{1, 2, 3})...)
+	body = append(body, chunk("EXIF", []byte("GPS data"))...)
+	body = append(body, chunk("XMP ", []byte("<x/>"))...)
+	data := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(body)))
+
+	out, err := stripWebPMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out, []byte("EXIF")) || bytes.Contains(out, []byte("XMP ")) {
+		t.Fatal("metadata chunks left in")
+	}
+	if !bytes.Contains(out, []byte("VP8L")) {
+		t.Fatal("image chunk dropped")
+	}
+	if flags := out[20]; flags != 0x10 {
+		t.Fatalf("VP8X flags %#x, want only the alpha flag", flags)
+	}
+	if size := binary.LittleEndian.Uint32(out[4:]); int(size) != len(out)-8 {
+		t.Fatalf("RIFF size %d for %d bytes", size, len(out))
+	}
+
+	if _, err := stripWebPMetadata([]byte("RIFF\x00\x00\x00\x00WAVE")); err == nil {
+		t.Fatal("non-WebP accepted")
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"eman-backend/config"
+	"eman-backend/models"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"golang.org/x/image/draw"
+	"golang.org/x/sync/singleflight"
+)
+
+// Larger sources are stored as-is without variants
+const maxVariantSourceSize = 32 * 1024 * 1024
+
+var (
+	ErrImageSizeNotAllowed = errors.New("image size not allowed")
+	ErrNotAnImage          = errors.New("not a resizable image")
+	ErrImageAnimated       = errors.New("animated images are served as uploaded")
+)
+
+var resizableExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// resizeImage scales img to width×height. A zero dimension follows the
+// aspect ratio; with both set the image is cropped to fill the box. Images
+// are never upscaled.
+func resizeImage(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	src := bounds
+
+	switch {
+	case width > 0 && height > 0:
+		// Crop the middle of the source to the box's aspect ratio
+		if srcW*height > srcH*width {
+			cropW := srcH * width / height
+			x0 := bounds.Min.X + (srcW-cropW)/2
+			src = image.Rect(x0, bounds.Min.Y, x0+cropW, bounds.Max.Y)
+		} else {
+			cropH := srcW * height / width
+			y0 := bounds.Min.Y + (srcH-cropH)/2
+			src = image.Rect(bounds.Min.X, y0, bounds.Max.X, y0+cropH)
+		}
+		if width > src.Dx() {
+			width, height = src.Dx(), src.Dy()
+		}
+	case width > 0:
+		width = min(width, srcW)
+		height = max(1, srcH*width/srcW)
+	case height > 0:
+		height = min(height, srcH)
+		width = max(1, srcW*height/srcH)
+	default:
+		return img
+	}
+
+	if src == bounds && width == srcW && height == srcH {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+// encodeWebP encodes img with the configured WebP settings.
+func encodeWebP(cfg *config.Config, img image.Image) (*bytes.Buffer, error) {
+	quality := cfg.WebPQuality
+	if quality <= 0 {
+		quality = 85
+	}
+	if quality > 100 {
+		quality = 100
+	}
+
+	options := &webp.Options{
+		Quality: float32(quality),
+	}
+
+	if cfg.WebPLossless {
+		options.Lossless = true
+	}
+	if cfg.WebPExact {
+		options.Exact = true
+	}
+
+	var buf bytes.Buffer
+	if err := webp.Encode(&buf, img, options); err != nil {
+		return nil, fmt.Errorf("failed to encode webp: %w", err)
+	}
+	return &buf, nil
+}
+
+// decodeStill decodes an image, refusing animated GIFs so they keep moving.
+func decodeStill(data []byte) (image.Image, error) {
+	if bytes.HasPrefix(data, []byte("GIF8")) {
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		if len(animation.Image) > 1 {
+			return nil, ErrImageAnimated
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// variantKey names the WebP copy of key at width: 2025/01/a.webp -> 2025/01/a_w480.webp
+func variantKey(key string, width int) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_w" + strconv.Itoa(width) + ".webp"
+}
+
+// writeVariants stores a downscaled copy of img for every configured width
+// narrower than the image. Failures only cost the variant.
+func (s *StorageService) writeVariants(key string, img image.Image) []models.MediaVariant {
+	widths := append([]int(nil), s.cfg.ImageVariantWidths...)
+	sort.Ints(widths)
+
+	var variants []models.MediaVariant
+	for _, width := range widths {
+		if width >= img.Bounds().Dx() {
+			break
+		}
+		resized := resizeImage(img, width, 0)
+		buf, err := encodeWebP(s.cfg, resized)
+		if err != nil {
+			log.Printf("[Storage] %s: variant %dw: %v", key, width, err)
+			continue
+		}
+		variant := models.MediaVariant{
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Path:   variantKey(key, width),
+		}
+		if err := s.backend.Put(variant.Path, buf, int64(buf.Len()), "image/webp"); err != nil {
+			log.Printf("[Storage] %s: variant %dw: %v", key, width, err)
+			continue
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// ImageService serves resized images on demand from a disk cache.
+type ImageService struct {
+	cfg     *config.Config
+	storage *StorageService
+	sizes   map[string][2]int
+	group   singleflight.Group
+}
+
+func NewImageService(cfg *config.Config, storage *StorageService) *ImageService {
+	sizes := make(map[string][2]int)
+	for _, size := range cfg.ImageResizeSizes {
+		width, height, ok := parseImageSize(size)
+		if !ok {
+			log.Printf("[Images] ignoring invalid size %q in IMAGE_RESIZE_SIZES", size)
+			continue
+		}
+		sizes[size] = [2]int{width, height}
+	}
+	return &ImageService{cfg: cfg, storage: storage, sizes: sizes}
+}
+
+// parseImageSize parses "WxH" where one side may be 0.
+func parseImageSize(size string) (int, int, bool) {
+	w, h, ok := strings.Cut(size, "x")
+	if !ok {
+		return 0, 0, false
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width < 0 || height < 0 || width+height == 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+func (s *ImageService) cachePath(size, key string) string {
+	return filepath.Join(s.cfg.ImageCacheDir, size, filepath.FromSlash(key)) + ".webp"
+}
+
+// Resize returns the cached file holding key resized to size, rendering it
+// on first request. Concurrent requests for the same file render it once.
+func (s *ImageService) Resize(size, key string) (string, error) {
+	dims, ok := s.sizes[size]
+	if !ok {
+		return "", ErrImageSizeNotAllowed
+	}
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" || IsReservedKey(key) {
+		return "", ErrObjectNotFound
+	}
+	if !resizableExtensions[strings.ToLower(path.Ext(key))] {
+		return "", ErrNotAnImage
+	}
+
+	cachePath := s.cachePath(size, key)
+	if _, err := os.Stat(cachePath); err == nil {
+		return cachePath, nil
+	}
+
+	_, err, _ := s.group.Do(cachePath, func() (interface{}, error) {
+		if _, err := os.Stat(cachePath); err == nil {
+			return nil, nil
+		}
+		return nil, s.render(key, dims[0], dims[1], cachePath)
+	})
+	if err != nil {
+		return "", err
+	}
+	return cachePath, nil
+}
+
+func (s *ImageService) render(key string, width, height int, cachePath string) error {
+	reader, err := s.storage.Backend().Get(key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxVariantSourceSize+1))
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) > maxVariantSourceSize {
+		return ErrNotAnImage
+	}
+
+	img, err := decodeStill(data)
+	if err != nil {
+		return err
+	}
+	buf, err := encodeWebP(s.cfg, resizeImage(img, width, height))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".resize-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cachePath)
+}
+
+// Purge drops cached resizes of key, e.g. after its file was deleted.
+func (s *ImageService) Purge(key string) {
+	for size := range s.sizes {
+		if err := os.Remove(s.cachePath(size, key)); err != nil && !os.IsNotExist(err) {
+			log.Printf("[Images] failed to purge %s at %s: %v", key, size, err)
+		}
+	}
+}
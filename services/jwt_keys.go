@@ -0,0 +1,280 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"eman-backend/config"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are carried by every JWT this service issues: admin access tokens
+// and the short-lived two-factor tokens. Refresh tokens are opaque.
+type Claims struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// JWTKey is one key of the keyset.
+type JWTKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   any // nil for verify-only keys
+	verifyKey any
+	public    crypto.PublicKey // set for asymmetric keys, published in the JWKS
+	retired   bool
+	retiredAt time.Time // tokens issued later are refused
+}
+
+// JWTKeySet signs tokens with the current key and verifies them with the
+// current key or, during the grace period, with a retired one.
+type JWTKeySet struct {
+	current *JWTKey
+	keys    map[string]*JWTKey
+	grace   time.Duration
+}
+
+func NewJWTKeySet(cfg *config.Config) (*JWTKeySet, error) {
+	set := &JWTKeySet{
+		keys:  make(map[string]*JWTKey),
+		grace: cfg.JWTKeyGracePeriod,
+	}
+
+	var defaultRetiredAt time.Time
+	if cfg.JWTKeysRetiredAt != "" {
+		parsed, err := time.Parse(time.RFC3339, cfg.JWTKeysRetiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_KEYS_RETIRED_AT: %w", err)
+		}
+		defaultRetiredAt = parsed
+	}
+
+	if cfg.JWTSigningKeyFile != "" {
+		key, err := loadJWTKeyFile(cfg.JWTSigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load JWT signing key: %w", err)
+		}
+		if key.signKey == nil {
+			return nil, fmt.Errorf("JWT signing key file %s holds a public key", cfg.JWTSigningKeyFile)
+		}
+		set.current = key
+		// The HS256 secret that signed tokens so far keeps verifying for the
+		// grace period, once the switch has a date
+		if cfg.JWTSecret != config.DefaultJWTSecret {
+			if defaultRetiredAt.IsZero() {
+				log.Printf("[JWT] JWT_KEYS_RETIRED_AT is not set, tokens signed with JWT_SECRET are no longer accepted")
+			} else {
+				set.addRetired(hmacJWTKey(cfg.JWTSecret), defaultRetiredAt)
+			}
+		}
+	} else {
+		set.current = hmacJWTKey(cfg.JWTSecret)
+	}
+	set.keys[set.current.ID] = set.current
+
+	for _, entry := range cfg.JWTPreviousSecrets {
+		secret, retiredAt, err := splitRetiredAt(entry, defaultRetiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PREVIOUS_SECRETS: %w", err)
+		}
+		set.addRetired(hmacJWTKey(secret), retiredAt)
+	}
+	for _, entry := range cfg.JWTPreviousKeyFiles {
+		path, retiredAt, err := splitRetiredAt(entry, defaultRetiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEY_FILES: %w", err)
+		}
+		key, err := loadJWTKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load previous JWT key %s: %w", path, err)
+		}
+		set.addRetired(key, retiredAt)
+	}
+
+	log.Printf("[JWT] signing with %s key %s, %d retired key(s) configured",
+		set.current.Method.Alg(), set.current.ID, len(set.keys)-1)
+	for _, key := range set.keys {
+		if key.retired {
+			log.Printf("[JWT] retired key %s accepted until %s", key.ID, key.retiredAt.Add(set.grace).Format(time.RFC3339))
+		}
+	}
+	return set, nil
+}
+
+// splitRetiredAt separates "value@<RFC 3339 time>". Without a time the
+// default applies; a key with neither would be trusted forever, so it is
+// an error.
+func splitRetiredAt(entry string, defaultRetiredAt time.Time) (string, time.Time, error) {
+	if i := strings.LastIndex(entry, "@"); i >= 0 {
+		if retiredAt, err := time.Parse(time.RFC3339, entry[i+1:]); err == nil {
+			return entry[:i], retiredAt, nil
+		}
+	}
+	if defaultRetiredAt.IsZero() {
+		return "", time.Time{}, errors.New("retirement time missing, append @<RFC 3339 time> or set JWT_KEYS_RETIRED_AT")
+	}
+	return entry, defaultRetiredAt, nil
+}
+
+func (s *JWTKeySet) addRetired(key *JWTKey, retiredAt time.Time) {
+	if _, exists := s.keys[key.ID]; exists {
+		return
+	}
+	key.retired = true
+	key.retiredAt = retiredAt
+	key.signKey = nil
+	s.keys[key.ID] = key
+}
+
+// accepts reports whether a retired key is still within its grace period.
+func (s *JWTKeySet) accepts(key *JWTKey) bool {
+	return !key.retired || time.Now().Before(key.retiredAt.Add(s.grace))
+}
+
+func hmacJWTKey(secret string) *JWTKey {
+	sum := sha256.Sum256([]byte(secret))
+	return &JWTKey{
+		ID:        "hs-" + hex.EncodeToString(sum[:6]),
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// loadJWTKeyFile reads an Ed25519 or RSA key in PEM form. Private keys can
+// sign; public keys only verify.
+func loadJWTKeyFile(path string) (*JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &JWTKey{}
+	if priv, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		edKey, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("unsupported Ed private key")
+		}
+		key.Method = jwt.SigningMethodEdDSA
+		key.signKey = edKey
+		key.public = edKey.Public()
+	} else if priv, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		key.Method = jwt.SigningMethodRS256
+		key.signKey = priv
+		key.public = &priv.PublicKey
+	} else if pub, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		key.Method = jwt.SigningMethodEdDSA
+		key.public = pub
+	} else if pub, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		key.Method = jwt.SigningMethodRS256
+		key.public = pub
+	} else {
+		return nil, errors.New("not an Ed25519 or RSA PEM key")
+	}
+
+	key.verifyKey = key.public
+	key.ID = jwkThumbprint(key.public)
+	return key, nil
+}
+
+// jwk returns the public JSON Web Key members of an asymmetric key.
+func jwk(pub crypto.PublicKey) map[string]string {
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(k),
+		}
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	}
+	return nil
+}
+
+// jwkThumbprint is the RFC 7638 key id: encoding/json sorts map keys, which
+// gives exactly the required member order.
+func jwkThumbprint(pub crypto.PublicKey) string {
+	encoded, _ := json.Marshal(jwk(pub))
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Sign issues a token with the current key.
+func (s *JWTKeySet) Sign(claims Claims) (string, error) {
+	token := jwt.NewWithClaims(s.current.Method, claims)
+	token.Header["kid"] = s.current.ID
+	return token.SignedString(s.current.signKey)
+}
+
+// Parse verifies a token and returns its claims. Retired keys are accepted
+// until their grace period ends, and only for tokens issued before they
+// were retired.
+func (s *JWTKeySet) Parse(tokenString string) (*Claims, error) {
+	var usedKey *JWTKey
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := s.current
+		if kid != "" {
+			key = s.keys[kid]
+		}
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		usedKey = key
+		return key.verifyKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	if usedKey.retired {
+		if !s.accepts(usedKey) || claims.IssuedAt == nil || claims.IssuedAt.After(usedKey.retiredAt) {
+			return nil, errors.New("token signed with a retired key")
+		}
+	}
+	return claims, nil
+}
+
+// JWKS lists the public keys that verify tokens. Shared secrets are never published.
+func (s *JWTKeySet) JWKS() map[string]any {
+	keys := make([]map[string]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.public == nil {
+			continue
+		}
+		if !s.accepts(key) {
+			continue
+		}
+		entry := jwk(key.public)
+		entry["kid"] = key.ID
+		entry["alg"] = key.Method.Alg()
+		entry["use"] = "sig"
+		keys = append(keys, entry)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i]["kid"] < keys[j]["kid"] })
+	return map[string]any{"keys": keys}
+}
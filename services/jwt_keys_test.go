@@ -0,0 +1,86 @@
+package services
+
+import (
+	"eman-backend/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signedWith(t *testing.T, secret string, issuedAt time.Time) string {
+	t.Helper()
+	set, err := NewJWTKeySet(&config.Config{JWTSecret: secret, JWTKeyGracePeriod: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := set.Sign(Claims{
+		Username: "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestJWTKeySetRetirement(t *testing.T) {
+	now := time.Now()
+	retiredAt := now.Add(-10 * time.Minute)
+	oldToken := signedWith(t, "old-secret", retiredAt.Add(-time.Minute))
+	lateToken := signedWith(t, "old-secret", retiredAt.Add(time.Minute))
+
+	set, err := NewJWTKeySet(&config.Config{
+		JWTSecret:          "new-secret",
+		JWTPreviousSecrets: []string{"old-secret@" + retiredAt.Format(time.RFC3339)},
+		JWTKeyGracePeriod:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(oldToken); err != nil {
+		t.Fatalf("token issued before retirement was refused: %v", err)
+	}
+	if _, err := set.Parse(lateToken); err == nil {
+		t.Fatal("token issued after retirement was accepted")
+	}
+
+	// The window is counted from the configured time, not from startup
+	expired, err := NewJWTKeySet(&config.Config{
+		JWTSecret:          "new-secret",
+		JWTPreviousSecrets: []string{"old-secret"},
+		JWTKeysRetiredAt:   now.Add(-2 * time.Hour).Format(time.RFC3339),
+		JWTKeyGracePeriod:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expired.Parse(signedWith(t, "old-secret", now.Add(-3*time.Hour))); err == nil {
+		t.Fatal("retired key was accepted after its grace period")
+	}
+}
+
+func TestJWTKeySetRequiresRetirementTime(t *testing.T) {
+	_, err := NewJWTKeySet(&config.Config{
+		JWTSecret:          "new-secret",
+		JWTPreviousSecrets: []string{"old-secret"},
+		JWTKeyGracePeriod:  time.Hour,
+	})
+	if err == nil {
+		t.Fatal("previous secret without a retirement time was accepted")
+	}
+}
+
+func TestSplitRetiredAt(t *testing.T) {
+	value, at, err := splitRetiredAt("p@ss@2024-05-01T10:00:00Z", time.Time{})
+	if err != nil || value != "p@ss" || !at.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got %q %v %v", value, at, err)
+	}
+	fallback := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if value, at, err := splitRetiredAt("p@ss", fallback); err != nil || value != "p@ss" || !at.Equal(fallback) {
+		t.Fatalf("got %q %v %v", value, at, err)
+	}
+}
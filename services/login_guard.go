@@ -0,0 +1,228 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxLoginDelay         = 30 * time.Second
+	loginAttemptRetention = 90 * 24 * time.Hour
+	loginCleanupInterval  = 24 * time.Hour
+)
+
+// LoginGuardService throttles and locks out repeated failed admin logins,
+// counting failures both per username and per client IP.
+type LoginGuardService struct {
+	cfg      *config.Config
+	telegram *TelegramService
+}
+
+func NewLoginGuardService(cfg *config.Config, telegram *TelegramService) *LoginGuardService {
+	service := &LoginGuardService{cfg: cfg, telegram: telegram}
+	service.startCleanupJob()
+	return service
+}
+
+func normalizeLoginUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginDelay is the wait imposed after the given number of consecutive
+// failures: none after the first, then 1s, 2s, 4s... capped at maxLoginDelay.
+func loginDelay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	if failures > 7 {
+		return maxLoginDelay
+	}
+	delay := time.Second << (failures - 2)
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay
+}
+
+// Check reports whether a login for username from ip may proceed. When it may
+// not, it returns how long the client should wait and whether it is locked
+// out or just throttled.
+func (s *LoginGuardService) Check(username, ip string) (time.Duration, string) {
+	var rows []models.LoginLockout
+	database.DB.
+		Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+			models.LockoutScopeUsername, normalizeLoginUsername(username),
+			models.LockoutScopeIP, ip).
+		Find(&rows)
+
+	now := time.Now()
+	var wait time.Duration
+	reason := ""
+	for _, row := range rows {
+		if row.LockedUntil != nil && row.LockedUntil.After(now) {
+			if d := row.LockedUntil.Sub(now); reason != models.LoginReasonLocked || d > wait {
+				wait = d
+			}
+			reason = models.LoginReasonLocked
+			continue
+		}
+		if reason != models.LoginReasonLocked && row.NextAttemptAt.After(now) {
+			if d := row.NextAttemptAt.Sub(now); d > wait {
+				wait = d
+			}
+			reason = models.LoginReasonThrottled
+		}
+	}
+	return wait, reason
+}
+
+// RecordAttempt stores a login attempt for the audit trail.
+func (s *LoginGuardService) RecordAttempt(username, ip, userAgent string, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		Username:  strings.TrimSpace(username),
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := database.DB.Create(&attempt).Error; err != nil {
+		log.Printf("[LoginGuard] failed to record login attempt: %v", err)
+	}
+}
+
+// RecordFailure records a failed password or 2FA check and bumps both counters.
+func (s *LoginGuardService) RecordFailure(username, ip, userAgent, reason string) {
+	s.RecordAttempt(username, ip, userAgent, false, reason)
+	s.registerFailure(models.LockoutScopeUsername, normalizeLoginUsername(username), s.cfg.LoginMaxFailures)
+	s.registerFailure(models.LockoutScopeIP, ip, s.cfg.LoginIPMaxFailures)
+}
+
+// RecordSuccess records a successful login and resets the counters.
+func (s *LoginGuardService) RecordSuccess(username, ip, userAgent string) {
+	s.RecordAttempt(username, ip, userAgent, true, models.LoginReasonOK)
+	database.DB.
+		Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+			models.LockoutScopeUsername, normalizeLoginUsername(username),
+			models.LockoutScopeIP, ip).
+		Delete(&models.LoginLockout{})
+}
+
+func (s *LoginGuardService) registerFailure(scope, key string, maxFailures int) {
+	if key == "" {
+		return
+	}
+
+	var locked *models.LoginLockout
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		seed := models.LoginLockout{Scope: scope, Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seed).Error; err != nil {
+			return err
+		}
+
+		var row models.LoginLockout
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND key = ?", scope, key).
+			First(&row).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// Start over once a lockout has run out or failures have gone quiet
+		if (row.LockedUntil != nil && !row.LockedUntil.After(now)) ||
+			now.Sub(row.LastFailureAt) > s.cfg.LoginLockout {
+			row.Failures = 0
+			row.LockedUntil = nil
+		}
+
+		row.Failures++
+		row.LastFailureAt = now
+		row.NextAttemptAt = now.Add(loginDelay(row.Failures))
+		if maxFailures > 0 && row.Failures >= maxFailures && row.LockedUntil == nil {
+			until := now.Add(s.cfg.LoginLockout)
+			row.LockedUntil = &until
+			row.NextAttemptAt = until
+			locked = &row
+		}
+
+		return tx.Save(&row).Error
+	})
+	if err != nil {
+		log.Printf("[LoginGuard] failed to register %s failure for %q: %v", scope, key, err)
+		return
+	}
+
+	if locked != nil {
+		log.Printf("[LoginGuard] %s %q locked until %s after %d failures", scope, key, locked.LockedUntil.Format(time.RFC3339), locked.Failures)
+		go s.alertLockout(*locked)
+	}
+}
+
+func (s *LoginGuardService) alertLockout(row models.LoginLockout) {
+	subject := "Логин"
+	if row.Scope == models.LockoutScopeIP {
+		subject = "IP"
+	}
+	text := fmt.Sprintf(
+		"🔒 Блокировка входа в админку\n%s: %s\nНеудачных попыток: %d\nЗаблокировано до: %s",
+		subject,
+		row.Key,
+		row.Failures,
+		row.LockedUntil.Format("02.01.2006 15:04"),
+	)
+	if err := s.telegram.SendMessage(text); err != nil {
+		log.Printf("[LoginGuard] failed to send lockout alert: %v", err)
+	}
+}
+
+// ActiveLockouts lists the usernames and IPs that are currently locked out.
+func (s *LoginGuardService) ActiveLockouts() ([]models.LoginLockout, error) {
+	var rows []models.LoginLockout
+	err := database.DB.
+		Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// Clear removes a lockout and its failure counter.
+func (s *LoginGuardService) Clear(id uint) error {
+	result := database.DB.Delete(&models.LoginLockout{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ClearUsername lifts the lockout of a username, e.g. after a password reset.
+func (s *LoginGuardService) ClearUsername(username string) {
+	database.DB.
+		Where("scope = ? AND key = ?", models.LockoutScopeUsername, normalizeLoginUsername(username)).
+		Delete(&models.LoginLockout{})
+}
+
+func (s *LoginGuardService) startCleanupJob() {
+	go func() {
+		ticker := time.NewTicker(loginCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			cutoff := time.Now().Add(-loginAttemptRetention)
+			if err := database.DB.Where("created_at < ?", cutoff).Delete(&models.LoginAttempt{}).Error; err != nil {
+				log.Printf("[LoginGuard] failed to purge login attempts: %v", err)
+			}
+			database.DB.
+				Where("(locked_until IS NULL OR locked_until < ?) AND last_failure_at < ?", time.Now(), time.Now().Add(-s.cfg.LoginLockout)).
+				Delete(&models.LoginLockout{})
+		}
+	}()
+}
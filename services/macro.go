@@ -246,31 +246,53 @@ func (s *MacroService) fetchEstateByID(id int) map[string]any {
 	return findEstateByID(estates, id)
 }
 
-// GetEstateTitleByID returns the human-readable estate title.
+// GetEstateByID returns the raw estate record for an id.
 // Priority: cached snapshot -> one forced refresh -> direct API by id.
-func (s *MacroService) GetEstateTitleByID(id int) string {
+func (s *MacroService) GetEstateByID(id int) map[string]any {
 	if id <= 0 {
-		return ""
+		return nil
 	}
 
 	if item := findEstateByID(s.getEstatesSnapshot(), id); item != nil {
-		return estateHumanTitle(item)
+		return item
 	}
 
 	// One opportunistic refresh for better hit chance.
 	if err := s.refreshEstatesSnapshot(); err == nil {
 		if item := findEstateByID(s.getEstatesSnapshot(), id); item != nil {
-			return estateHumanTitle(item)
+			return item
 		}
 	}
 
-	if item := s.fetchEstateByID(id); item != nil {
+	return s.fetchEstateByID(id)
+}
+
+// GetCachedEstateByID looks an estate up in the cached snapshot only.
+func (s *MacroService) GetCachedEstateByID(id int) map[string]any {
+	return findEstateByID(s.getEstatesSnapshot(), id)
+}
+
+// GetEstateTitleByID returns the human-readable estate title.
+func (s *MacroService) GetEstateTitleByID(id int) string {
+	if item := s.GetEstateByID(id); item != nil {
 		return estateHumanTitle(item)
 	}
-
 	return ""
 }
 
+// GetEstatePriceByID returns the estate_price of an estate, if known.
+func (s *MacroService) GetEstatePriceByID(id int) (float64, bool) {
+	item := s.GetEstateByID(id)
+	if item == nil {
+		return 0, false
+	}
+	price, ok := getNumberField(item, "estate_price")
+	if !ok || price <= 0 {
+		return 0, false
+	}
+	return price, true
+}
+
 // GetDefaultEstateID returns the first valid estate id from the Macro estate feed.
 // Priority: cached snapshot -> one forced refresh.
 func (s *MacroService) GetDefaultEstateID() *int {
@@ -287,7 +309,14 @@ func (s *MacroService) GetDefaultEstateID() *int {
 
 func getNumberField(item map[string]any, key string) (float64, bool) {
 	v, ok := item[key]
-	if !ok || v == nil {
+	if !ok {
+		return 0, false
+	}
+	return toNumber(v)
+}
+
+func toNumber(v any) (float64, bool) {
+	if v == nil {
 		return 0, false
 	}
 
@@ -477,7 +506,9 @@ func (s *MacroService) applyEstateFilters(estates []map[string]any, params url.V
 	return filtered[offset:end]
 }
 
-func (s *MacroService) GetEstates(params url.Values) (json.RawMessage, error) {
+// ListEstates returns the filtered estates from the cached snapshot.
+// The returned maps are shared with the cache and must not be modified.
+func (s *MacroService) ListEstates(params url.Values) ([]map[string]any, error) {
 	estates := s.getEstatesSnapshot()
 	if len(estates) == 0 {
 		// Try immediate refresh on cold start / empty cache.
@@ -487,10 +518,5 @@ func (s *MacroService) GetEstates(params url.Values) (json.RawMessage, error) {
 		estates = s.getEstatesSnapshot()
 	}
 
-	filtered := s.applyEstateFilters(estates, params)
-	out, err := json.Marshal(filtered)
-	if err != nil {
-		return nil, fmt.Errorf("encode estates response failed: %w", err)
-	}
-	return out, nil
+	return s.applyEstateFilters(estates, params), nil
 }
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"eman-backend/config"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailerService sends plain-text email over SMTP.
+type MailerService struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewMailerService(cfg *config.Config) *MailerService {
+	return &MailerService{
+		host:     sanitizeEnvValue(cfg.SMTPHost),
+		port:     cfg.SMTPPort,
+		username: sanitizeEnvValue(cfg.SMTPUsername),
+		password: sanitizeEnvValue(cfg.SMTPPassword),
+		from:     sanitizeEnvValue(cfg.SMTPFrom),
+	}
+}
+
+func (s *MailerService) Enabled() bool {
+	return s != nil && s.host != "" && s.from != ""
+}
+
+// Send delivers a single message to one recipient.
+func (s *MailerService) Send(to, subject, body string) error {
+	if !s.Enabled() {
+		return fmt.Errorf("smtp is not configured")
+	}
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + s.from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	addr := s.host + ":" + strconv.Itoa(s.port)
+	if err := smtp.SendMail(addr, auth, s.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("smtp send failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const mediaCleanupInterval = time.Hour
+
+var (
+	ErrMediaInUse          = errors.New("media asset is still referenced")
+	ErrMediaNotQuarantined = errors.New("media asset is not quarantined")
+)
+
+// Storage keys as generated by saveFromReader ("2006/01/<name>.<ext>"),
+// found in plain paths, /uploads URLs, CDN URLs and JSON setting values alike.
+var mediaKeyPattern = regexp.MustCompile(`\d{4}/\d{2}/[\w-]+\.[A-Za-z0-9]+`)
+
+// Variant keys point back to their source: 2025/01/a_w480.webp -> 2025/01/a.*
+var variantKeyPattern = regexp.MustCompile(`^(.+)_w\d+\.webp$`)
+
+// MediaFields maps an entity's field names to their values.
+type MediaFields map[string]string
+
+// MediaService keeps the media library: which uploads exist, which
+// entities use them, and removal of files nothing has used for a while.
+type MediaService struct {
+	cfg     *config.Config
+	storage *StorageService
+	images  *ImageService
+}
+
+func NewMediaService(cfg *config.Config, storage *StorageService, images *ImageService) *MediaService {
+	service := &MediaService{cfg: cfg, storage: storage, images: images}
+	service.startCleanupJob()
+	return service
+}
+
+// Record adds a freshly stored file to the library. Until something
+// references it, it counts as unreferenced from the moment of upload.
+func (s *MediaService) Record(file *StoredFile, uploadedBy string) (*models.MediaAsset, error) {
+	now := time.Now()
+	asset := models.MediaAsset{
+		Path:              file.Path,
+		OriginalName:      file.OriginalName,
+		Size:              file.Size,
+		MimeType:          file.MimeType,
+		Width:             file.Width,
+		Height:            file.Height,
+		Checksum:          file.Checksum,
+		Variants:          file.Variants,
+		UploadedBy:        uploadedBy,
+		UnreferencedSince: &now,
+		ScanStatus:        file.ScanStatus,
+		ScanSignature:     file.ScanSignature,
+		ScannedAt:         file.ScannedAt,
+	}
+	if err := database.DB.Create(&asset).Error; err != nil {
+		return nil, err
+	}
+	if asset.ScanStatus != models.ScanInfected {
+		asset.URL = s.storage.URL(asset.Path)
+	}
+	return &asset, nil
+}
+
+// TrackID is Track for entities with a numeric primary key.
+func (s *MediaService) TrackID(entityType string, id uint, fields MediaFields) {
+	s.Track(entityType, strconv.FormatUint(uint64(id), 10), fields)
+}
+
+// Track replaces the entity's references with the library files its fields
+// point at. Failures are logged; the entity itself is already saved.
+func (s *MediaService) Track(entityType, entityID string, fields MediaFields) {
+	if err := s.track(entityType, entityID, fields); err != nil {
+		log.Printf("[Media] failed to track references of %s %s: %v", entityType, entityID, err)
+	}
+}
+
+// ReleaseID is Release for entities with a numeric primary key.
+func (s *MediaService) ReleaseID(entityType string, id uint) {
+	s.Release(entityType, strconv.FormatUint(uint64(id), 10))
+}
+
+// Release drops all references held by a deleted entity.
+func (s *MediaService) Release(entityType, entityID string) {
+	s.Track(entityType, entityID, nil)
+}
+
+// ReleaseType drops the references of every entity of a type.
+func (s *MediaService) ReleaseType(entityType string) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var assetIDs []uint
+		if err := tx.Model(&models.MediaReference{}).
+			Where("entity_type = ?", entityType).
+			Distinct().Pluck("asset_id", &assetIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("entity_type = ?", entityType).Delete(&models.MediaReference{}).Error; err != nil {
+			return err
+		}
+		return recountReferences(tx, assetIDs)
+	})
+	if err != nil {
+		log.Printf("[Media] failed to release references of %s: %v", entityType, err)
+	}
+}
+
+func (s *MediaService) track(entityType, entityID string, fields MediaFields) error {
+	keysByField := map[string][]string{}
+	var keys []string
+	for field, value := range fields {
+		for _, key := range mediaKeyPattern.FindAllString(value, -1) {
+			keysByField[field] = append(keysByField[field], key)
+			keys = append(keys, key)
+		}
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var previous []uint
+		if err := tx.Model(&models.MediaReference{}).
+			Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+			Pluck("asset_id", &previous).Error; err != nil {
+			return err
+		}
+		if len(previous) == 0 && len(keys) == 0 {
+			return nil
+		}
+
+		var assets []models.MediaAsset
+		if len(keys) > 0 {
+			if err := tx.Select("id", "path").Where("path IN ? AND scan_status <> ?", keys, models.ScanInfected).Find(&assets).Error; err != nil {
+				return err
+			}
+		}
+		idByPath := make(map[string]uint, len(assets))
+		for _, asset := range assets {
+			idByPath[asset.Path] = asset.ID
+		}
+		for _, key := range keys {
+			match := variantKeyPattern.FindStringSubmatch(key)
+			if _, known := idByPath[key]; known || match == nil {
+				continue
+			}
+			var source models.MediaAsset
+			if err := tx.Select("id").Where("path LIKE ? AND scan_status <> ?", match[1]+".%", models.ScanInfected).Limit(1).Find(&source).Error; err != nil {
+				return err
+			}
+			if source.ID != 0 {
+				idByPath[key] = source.ID
+			}
+		}
+
+		if err := tx.Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+			Delete(&models.MediaReference{}).Error; err != nil {
+			return err
+		}
+
+		var refs []models.MediaReference
+		seen := map[string]bool{}
+		for field, fieldKeys := range keysByField {
+			for _, key := range fieldKeys {
+				id, ok := idByPath[key]
+				if !ok || seen[field+"\x00"+key] {
+					continue // not an upload we know about, or a duplicate
+				}
+				seen[field+"\x00"+key] = true
+				refs = append(refs, models.MediaReference{
+					AssetID:    id,
+					EntityType: entityType,
+					EntityID:   entityID,
+					Field:      field,
+				})
+			}
+		}
+		if len(refs) > 0 {
+			if err := tx.Create(&refs).Error; err != nil {
+				return err
+			}
+		}
+
+		affected := previous
+		for _, id := range idByPath {
+			affected = append(affected, id)
+		}
+		return recountReferences(tx, affected)
+	})
+}
+
+// recountReferences refreshes RefCount and UnreferencedSince of the assets.
+func recountReferences(tx *gorm.DB, assetIDs []uint) error {
+	if len(assetIDs) == 0 {
+		return nil
+	}
+	if err := tx.Exec(`UPDATE media_assets SET ref_count =
+		(SELECT COUNT(*) FROM media_references r WHERE r.asset_id = media_assets.id)
+		WHERE id IN ?`, assetIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`UPDATE media_assets SET unreferenced_since = NULL
+		WHERE id IN ? AND ref_count > 0`, assetIDs).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`UPDATE media_assets SET unreferenced_since = ?
+		WHERE id IN ? AND ref_count = 0 AND unreferenced_since IS NULL`, time.Now(), assetIDs).Error
+}
+
+// Delete removes an unreferenced asset and its file.
+func (s *MediaService) Delete(id uint) error {
+	return s.remove(id, time.Time{})
+}
+
+// remove deletes the asset if it is still unreferenced (since before
+// cutoff, when set). The row is locked so a concurrent Track either sees
+// it gone or keeps it alive.
+func (s *MediaService) remove(id uint, cutoff time.Time) error {
+	var asset models.MediaAsset
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&asset, id).Error; err != nil {
+			return err
+		}
+		if asset.RefCount > 0 {
+			return ErrMediaInUse
+		}
+		if !cutoff.IsZero() && (asset.UnreferencedSince == nil || asset.UnreferencedSince.After(cutoff)) {
+			return ErrMediaInUse
+		}
+		return tx.Delete(&asset).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	paths := append([]string{asset.Path}, variantPaths(asset.Variants)...)
+	if asset.ScanStatus == models.ScanInfected {
+		paths = []string{QuarantineKey(asset.Path)}
+	}
+	for _, key := range paths {
+		if err := s.storage.DeleteFile(key); err != nil {
+			log.Printf("[Media] failed to delete file %s: %v", key, err)
+		}
+	}
+	s.images.Purge(asset.Path)
+	return nil
+}
+
+// Restore releases a quarantined asset an admin judged a false positive,
+// putting its file back at its public path. Image variants are not rebuilt.
+func (s *MediaService) Restore(id uint) (*models.MediaAsset, error) {
+	var asset models.MediaAsset
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&asset, id).Error; err != nil {
+			return err
+		}
+		if asset.ScanStatus != models.ScanInfected {
+			return ErrMediaNotQuarantined
+		}
+		if err := s.storage.moveFile(QuarantineKey(asset.Path), asset.Path); err != nil {
+			return err
+		}
+		now := time.Now()
+		asset.ScanStatus = models.ScanRestored
+		asset.UnreferencedSince = &now
+		return tx.Model(&asset).Updates(map[string]interface{}{
+			"scan_status":        asset.ScanStatus,
+			"unreferenced_since": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	asset.URL = s.storage.URL(asset.Path)
+	return &asset, nil
+}
+
+func (s *MediaService) startCleanupJob() {
+	go func() {
+		ticker := time.NewTicker(mediaCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.collectGarbage()
+		}
+	}()
+}
+
+// collectGarbage deletes files nothing has referenced for the grace period.
+// Quarantined files wait for an admin.
+func (s *MediaService) collectGarbage() {
+	cutoff := time.Now().Add(-s.cfg.MediaGCGrace)
+
+	var ids []uint
+	if err := database.DB.Model(&models.MediaAsset{}).
+		Where("ref_count = 0 AND unreferenced_since < ? AND scan_status <> ?", cutoff, models.ScanInfected).
+		Order("id").Limit(500).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("[Media] failed to find unreferenced files: %v", err)
+		return
+	}
+
+	removed := 0
+	for _, id := range ids {
+		switch err := s.remove(id, cutoff); {
+		case err == nil:
+			removed++
+		case errors.Is(err, ErrMediaInUse), errors.Is(err, gorm.ErrRecordNotFound):
+			// Referenced again, or another instance got there first
+		default:
+			log.Printf("[Media] failed to remove asset %d: %v", id, err)
+		}
+	}
+	if removed > 0 {
+		log.Printf("[Media] removed %d files unreferenced since before %s", removed, cutoff.Format(time.RFC3339))
+	}
+}
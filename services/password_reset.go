@@ -0,0 +1,221 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPasswordResetUnavailable = errors.New("password reset is not available for this account")
+	ErrPasswordResetTooSoon     = errors.New("a reset was requested moments ago")
+	ErrPasswordResetInvalid     = errors.New("invalid or expired reset token")
+	ErrPasswordUnchanged        = errors.New("new password matches the current one")
+)
+
+const (
+	passwordResetCooldown        = time.Minute
+	passwordResetCleanupInterval = 24 * time.Hour
+	passwordResetRetention       = 30 * 24 * time.Hour
+)
+
+// PasswordResetService issues single-use reset tokens and delivers them to
+// the admin's linked Telegram chat or email address.
+type PasswordResetService struct {
+	cfg      *config.Config
+	telegram *TelegramService
+	mailer   *MailerService
+	sessions *SessionService
+}
+
+func NewPasswordResetService(cfg *config.Config, telegram *TelegramService, mailer *MailerService, sessions *SessionService) *PasswordResetService {
+	service := &PasswordResetService{cfg: cfg, telegram: telegram, mailer: mailer, sessions: sessions}
+	service.startCleanupJob()
+	return service
+}
+
+// channelFor picks the delivery channel: the requested one if it is usable,
+// otherwise Telegram before email.
+func (s *PasswordResetService) channelFor(admin *models.AdminUser, requested string) string {
+	telegramOK := admin.TelegramChatID != "" && s.telegram.BotEnabled()
+	emailOK := admin.Email != "" && s.mailer.Enabled()
+
+	switch requested {
+	case models.ResetChannelTelegram:
+		if telegramOK {
+			return models.ResetChannelTelegram
+		}
+	case models.ResetChannelEmail:
+		if emailOK {
+			return models.ResetChannelEmail
+		}
+	}
+	if telegramOK {
+		return models.ResetChannelTelegram
+	}
+	if emailOK {
+		return models.ResetChannelEmail
+	}
+	return ""
+}
+
+// Request issues a reset token for an enabled admin and sends it in the
+// background. Earlier unused tokens of the admin stop working.
+func (s *PasswordResetService) Request(admin *models.AdminUser, requestedChannel, ip string) error {
+	if admin.Disabled {
+		return ErrPasswordResetUnavailable
+	}
+	channel := s.channelFor(admin, requestedChannel)
+	if channel == "" {
+		return ErrPasswordResetUnavailable
+	}
+
+	var recent int64
+	database.DB.Model(&models.PasswordResetToken{}).
+		Where("admin_user_id = ? AND created_at > ?", admin.ID, time.Now().Add(-passwordResetCooldown)).
+		Count(&recent)
+	if recent > 0 {
+		return ErrPasswordResetTooSoon
+	}
+
+	raw, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	token := models.PasswordResetToken{
+		AdminUserID: admin.ID,
+		TokenHash:   hashRefreshToken(raw),
+		Channel:     channel,
+		RequestIP:   ip,
+		ExpiresAt:   now.Add(s.cfg.PasswordResetTTL),
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("admin_user_id = ? AND used_at IS NULL", admin.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	go s.deliver(*admin, channel, raw, token.ExpiresAt)
+	return nil
+}
+
+// RevokeOutstanding invalidates the admin's unused reset tokens, e.g. after
+// the delivery address changed.
+func (s *PasswordResetService) RevokeOutstanding(adminID uint) error {
+	return database.DB.Model(&models.PasswordResetToken{}).
+		Where("admin_user_id = ? AND used_at IS NULL", adminID).
+		Update("used_at", time.Now()).Error
+}
+
+func (s *PasswordResetService) resetLink(raw string) string {
+	if s.cfg.PasswordResetURL == "" {
+		return raw
+	}
+	separator := "?"
+	if strings.Contains(s.cfg.PasswordResetURL, "?") {
+		separator = "&"
+	}
+	return s.cfg.PasswordResetURL + separator + "token=" + url.QueryEscape(raw)
+}
+
+func (s *PasswordResetService) deliver(admin models.AdminUser, channel, raw string, expiresAt time.Time) {
+	text := fmt.Sprintf(
+		"🔑 Сброс пароля для входа в админку\nЛогин: %s\n%s\nДействует до: %s\n\nЕсли вы не запрашивали сброс, просто проигнорируйте это сообщение.",
+		admin.Username,
+		s.resetLink(raw),
+		expiresAt.Format("02.01.2006 15:04"),
+	)
+
+	var err error
+	switch channel {
+	case models.ResetChannelTelegram:
+		err = s.telegram.SendMessageTo(admin.TelegramChatID, text)
+	case models.ResetChannelEmail:
+		err = s.mailer.Send(admin.Email, "Сброс пароля", text)
+	}
+	if err != nil {
+		log.Printf("[PasswordReset] failed to deliver reset for %q via %s: %v", admin.Username, channel, err)
+	}
+}
+
+// Confirm sets a new password with a reset token. The token is consumed only
+// when the password is accepted; all sessions of the admin are revoked.
+func (s *PasswordResetService) Confirm(raw, newPassword string) (*models.AdminUser, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	var admin models.AdminUser
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashRefreshToken(raw)).
+			First(&token).Error; err != nil {
+			return ErrPasswordResetInvalid
+		}
+		now := time.Now()
+		if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+			return ErrPasswordResetInvalid
+		}
+
+		if err := tx.First(&admin, token.AdminUserID).Error; err != nil || admin.Disabled {
+			return ErrPasswordResetInvalid
+		}
+		if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(newPassword)) == nil {
+			return ErrPasswordUnchanged
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&admin).Updates(map[string]interface{}{
+			"password_hash":       string(hash),
+			"password_changed_at": &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.RevokeAll(admin.ID, models.SessionRevokedPasswordChange); err != nil {
+		log.Printf("[PasswordReset] failed to revoke sessions of %q: %v", admin.Username, err)
+	}
+	go func(username string) {
+		if err := s.telegram.SendMessage(fmt.Sprintf("🔑 Пароль администратора %s сброшен, все сеансы завершены", username)); err != nil {
+			log.Printf("[PasswordReset] failed to send reset notice: %v", err)
+		}
+	}(admin.Username)
+	return &admin, nil
+}
+
+func (s *PasswordResetService) startCleanupJob() {
+	go func() {
+		ticker := time.NewTicker(passwordResetCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			cutoff := time.Now().Add(-passwordResetRetention)
+			if err := database.DB.Where("expires_at < ?", cutoff).Delete(&models.PasswordResetToken{}).Error; err != nil {
+				log.Printf("[PasswordReset] failed to purge reset tokens: %v", err)
+			}
+		}
+	}()
+}
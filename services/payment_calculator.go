@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MaxTermMonths caps the schedule length; one row is built per month.
+const MaxTermMonths = 600
+
+// PaymentPlan mirrors one entry of the payment_plans setting. The display
+// fields are free text for the website, the numeric ones drive the calculator.
+type PaymentPlan struct {
+	Title                 string   `json:"title"`
+	Description           string   `json:"description"`
+	Price                 string   `json:"price"`
+	Period                string   `json:"period"`
+	Features              []string `json:"features"`
+	MinDownPaymentPercent float64  `json:"min_down_payment_percent"`
+	TermMonths            int      `json:"term_months"`
+	InterestRate          float64  `json:"interest_rate"` // annual, percent
+	DiscountPercent       float64  `json:"discount_percent"`
+}
+
+// PaymentCalculationInput describes what the visitor picked in the calculator.
+// DownPaymentPercent and TermMonths are optional and default to the plan limits.
+type PaymentCalculationInput struct {
+	Price              float64 `json:"price"`
+	DownPaymentPercent float64 `json:"down_payment_percent"`
+	TermMonths         int     `json:"term_months"`
+}
+
+// PaymentScheduleRow is a single month of the amortization schedule.
+type PaymentScheduleRow struct {
+	Month     int     `json:"month"`
+	Payment   float64 `json:"payment"`
+	Principal float64 `json:"principal"`
+	Interest  float64 `json:"interest"`
+	Balance   float64 `json:"balance"`
+}
+
+// PaymentCalculation is the full calculator result.
+type PaymentCalculation struct {
+	EstateID           *int                 `json:"estate_id,omitempty"`
+	Plan               string               `json:"plan"`
+	Price              float64              `json:"price"`
+	DiscountPercent    float64              `json:"discount_percent"`
+	DiscountAmount     float64              `json:"discount_amount"`
+	FinalPrice         float64              `json:"final_price"`
+	DownPaymentPercent float64              `json:"down_payment_percent"`
+	DownPayment        float64              `json:"down_payment"`
+	FinancedAmount     float64              `json:"financed_amount"`
+	TermMonths         int                  `json:"term_months"`
+	InterestRate       float64              `json:"interest_rate"`
+	MonthlyPayment     float64              `json:"monthly_payment"`
+	TotalInterest      float64              `json:"total_interest"`
+	TotalPayment       float64              `json:"total_payment"`
+	Schedule           []PaymentScheduleRow `json:"schedule"`
+}
+
+// ParsePaymentPlans decodes the JSON value of a payment_plans setting.
+func ParsePaymentPlans(raw string) ([]PaymentPlan, error) {
+	var plans []PaymentPlan
+	if err := json.Unmarshal([]byte(raw), &plans); err != nil {
+		return nil, fmt.Errorf("decode payment plans failed: %w", err)
+	}
+	return plans, nil
+}
+
+// FindPaymentPlan picks a plan by title (case-insensitive) or, when the title
+// is empty, by zero-based index.
+func FindPaymentPlan(plans []PaymentPlan, title string, index int) (*PaymentPlan, error) {
+	title = strings.TrimSpace(title)
+	if title != "" {
+		for i := range plans {
+			if strings.EqualFold(strings.TrimSpace(plans[i].Title), title) {
+				return &plans[i], nil
+			}
+		}
+		return nil, fmt.Errorf("payment plan %q not found", title)
+	}
+
+	if index < 0 || index >= len(plans) {
+		return nil, fmt.Errorf("payment plan index %d out of range", index)
+	}
+	return &plans[index], nil
+}
+
+// CalculatePayment computes the down payment, monthly annuity payment and the
+// amortization schedule for a price under the given plan.
+func CalculatePayment(plan PaymentPlan, input PaymentCalculationInput) (*PaymentCalculation, error) {
+	if input.Price <= 0 {
+		return nil, fmt.Errorf("price must be positive")
+	}
+	if plan.DiscountPercent < 0 || plan.DiscountPercent >= 100 {
+		return nil, fmt.Errorf("plan discount must be between 0 and 100")
+	}
+	if plan.InterestRate < 0 {
+		return nil, fmt.Errorf("plan interest rate must not be negative")
+	}
+	if plan.TermMonths <= 0 || plan.TermMonths > MaxTermMonths {
+		return nil, fmt.Errorf("payment plan %q has no valid term configured", plan.Title)
+	}
+
+	downPercent := input.DownPaymentPercent
+	if downPercent <= 0 {
+		downPercent = plan.MinDownPaymentPercent
+	}
+	if downPercent < plan.MinDownPaymentPercent {
+		return nil, fmt.Errorf("down payment must be at least %s%%", formatPercent(plan.MinDownPaymentPercent))
+	}
+	if downPercent > 100 {
+		return nil, fmt.Errorf("down payment must not exceed 100%%")
+	}
+
+	term := input.TermMonths
+	if term <= 0 {
+		term = plan.TermMonths
+	}
+	if term > plan.TermMonths {
+		return nil, fmt.Errorf("term must not exceed %d months", plan.TermMonths)
+	}
+
+	discountAmount := roundMoney(input.Price * plan.DiscountPercent / 100)
+	finalPrice := roundMoney(input.Price - discountAmount)
+
+	downPayment := roundMoney(finalPrice * downPercent / 100)
+	financed := roundMoney(finalPrice - downPayment)
+	if financed <= 0 {
+		term = 0
+	}
+
+	result := &PaymentCalculation{
+		Plan:               plan.Title,
+		Price:              roundMoney(input.Price),
+		DiscountPercent:    plan.DiscountPercent,
+		DiscountAmount:     discountAmount,
+		FinalPrice:         finalPrice,
+		DownPaymentPercent: downPercent,
+		DownPayment:        downPayment,
+		FinancedAmount:     financed,
+		TermMonths:         term,
+		InterestRate:       plan.InterestRate,
+		Schedule:           []PaymentScheduleRow{},
+	}
+
+	if term == 0 {
+		result.TotalPayment = downPayment
+		return result, nil
+	}
+
+	monthlyRate := plan.InterestRate / 12 / 100
+	monthly := financed / float64(term)
+	if monthlyRate > 0 {
+		monthly = financed * monthlyRate / (1 - math.Pow(1+monthlyRate, -float64(term)))
+	}
+	monthly = roundMoney(monthly)
+
+	balance := financed
+	totalInterest := 0.0
+	for month := 1; month <= term; month++ {
+		interest := roundMoney(balance * monthlyRate)
+		principal := roundMoney(monthly - interest)
+		payment := monthly
+
+		// The last instalment absorbs rounding so the balance lands on zero.
+		if month == term || principal > balance {
+			principal = roundMoney(balance)
+			payment = roundMoney(principal + interest)
+		}
+
+		balance = roundMoney(balance - principal)
+		totalInterest += interest
+
+		result.Schedule = append(result.Schedule, PaymentScheduleRow{
+			Month:     month,
+			Payment:   payment,
+			Principal: principal,
+			Interest:  interest,
+			Balance:   balance,
+		})
+
+		if balance <= 0 {
+			break
+		}
+	}
+
+	result.MonthlyPayment = monthly
+	result.TotalInterest = roundMoney(totalInterest)
+	result.TotalPayment = roundMoney(downPayment + financed + totalInterest)
+	return result, nil
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func formatPercent(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
@@ -0,0 +1,26 @@
+package services
+
+import "testing"
+
+func TestCalculatePaymentTermLimits(t *testing.T) {
+	plan := PaymentPlan{Title: "Рассрочка", MinDownPaymentPercent: 20, TermMonths: 24}
+
+	if _, err := CalculatePayment(plan, PaymentCalculationInput{Price: 1000, TermMonths: 2000000000}); err == nil {
+		t.Fatal("term beyond the plan was accepted")
+	}
+
+	result, err := CalculatePayment(plan, PaymentCalculationInput{Price: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TermMonths != 24 || len(result.Schedule) != 24 || result.DownPayment != 200 {
+		t.Fatalf("unexpected result: term %d, %d rows, down %v", result.TermMonths, len(result.Schedule), result.DownPayment)
+	}
+
+	for _, term := range []int{0, MaxTermMonths + 1} {
+		plan.TermMonths = term
+		if _, err := CalculatePayment(plan, PaymentCalculationInput{Price: 1000}); err == nil {
+			t.Fatalf("plan with term %d was accepted", term)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"eman-backend/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Infected uploads are moved under this prefix. The local /uploads route
+// refuses it; with S3, keep it and stagingPrefix out of the bucket's
+// public-read policy.
+const quarantinePrefix = "_quarantine/"
+
+// Uploads wait under this prefix, out of public reach, until scanned.
+const stagingPrefix = "_staging/"
+
+// QuarantineKey is where the file of an infected asset is kept.
+func QuarantineKey(key string) string {
+	return quarantinePrefix + key
+}
+
+func (s *StorageService) scanMaxSize() int64 {
+	return int64(s.cfg.ScanMaxSizeMB) * 1024 * 1024
+}
+
+func (s *StorageService) errScanTooLarge() error {
+	return fmt.Errorf("file too large to be scanned, max size is %dMB", s.cfg.ScanMaxSizeMB)
+}
+
+// scan runs the malware scanner over a freshly staged file and publishes
+// it when clean. Infected files are moved to quarantine; if the scanner
+// cannot be reached the upload is refused unless SCAN_FAIL_OPEN is set.
+func (s *StorageService) scan(stored *StoredFile) error {
+	if stored.Size > s.scanMaxSize() {
+		s.discard(stored)
+		return s.errScanTooLarge()
+	}
+
+	reader, err := s.backend.Get(stored.Path)
+	var result *ScanResult
+	if err == nil {
+		result, err = s.scanner.Scan(reader)
+		reader.Close()
+	}
+	now := time.Now()
+	stored.ScannedAt = &now
+
+	if err != nil {
+		log.Printf("[Scanner] %s: %v", stored.Path, err)
+		if s.cfg.ScanFailOpen {
+			stored.ScanStatus = models.ScanFailed
+			return s.publish(stored)
+		}
+		s.discard(stored)
+		return ErrScanUnavailable
+	}
+	if !result.Infected {
+		stored.ScanStatus = models.ScanClean
+		return s.publish(stored)
+	}
+
+	stored.ScanStatus = models.ScanInfected
+	stored.ScanSignature = result.Signature
+	staged := stored.Path
+	stored.Path = strings.TrimPrefix(staged, stagingPrefix)
+	log.Printf("[Scanner] %s (%s): %s found, moving to quarantine", stored.Path, stored.OriginalName, result.Signature)
+	for _, variant := range stored.Variants {
+		s.DeleteFile(variant.Path)
+	}
+	stored.Variants = nil
+	if err := s.moveFile(staged, QuarantineKey(stored.Path)); err != nil {
+		log.Printf("[Scanner] failed to quarantine %s, deleting it: %v", stored.Path, err)
+		s.DeleteFile(staged)
+		return fmt.Errorf("malware detected: %s", result.Signature)
+	}
+	return &InfectedError{File: stored}
+}
+
+// publish moves a staged file and its variants to their public keys, the
+// file itself last so that its URL never points at missing variants.
+func (s *StorageService) publish(stored *StoredFile) error {
+	fail := func(err error) error {
+		log.Printf("[Storage] failed to publish %s: %v", stored.Path, err)
+		s.discard(stored) // variant paths already point where each one is
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	for i, variant := range stored.Variants {
+		key := strings.TrimPrefix(variant.Path, stagingPrefix)
+		if err := s.moveFile(variant.Path, key); err != nil {
+			return fail(err)
+		}
+		stored.Variants[i].Path = key
+	}
+	key := strings.TrimPrefix(stored.Path, stagingPrefix)
+	if err := s.moveFile(stored.Path, key); err != nil {
+		return fail(err)
+	}
+	stored.Path = key
+	return nil
+}
+
+// discard deletes a stored file along with its variants.
+func (s *StorageService) discard(stored *StoredFile) {
+	for _, key := range append([]string{stored.Path}, variantPaths(stored.Variants)...) {
+		if err := s.DeleteFile(key); err != nil {
+			log.Printf("[Storage] failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+func variantPaths(variants []models.MediaVariant) []string {
+	paths := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		paths = append(paths, variant.Path)
+	}
+	return paths
+}
+
+// moveFile copies a file to a new key and deletes the original.
+func (s *StorageService) moveFile(from, to string) error {
+	info, err := s.backend.Stat(from)
+	if err != nil {
+		return err
+	}
+	reader, err := s.backend.Get(from)
+	if err != nil {
+		return err
+	}
+	err = s.backend.Put(to, reader, info.Size, info.ContentType)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	return s.backend.Delete(from)
+}
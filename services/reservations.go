@@ -0,0 +1,305 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrEstateAlreadyReserved = errors.New("estate is already reserved")
+	ErrReservationNotActive  = errors.New("reservation is not active")
+)
+
+const reservationExpiryInterval = time.Minute
+
+type ReservationService struct {
+	cfg   *config.Config
+	macro *MacroService
+}
+
+func NewReservationService(cfg *config.Config, macro *MacroService) *ReservationService {
+	service := &ReservationService{
+		cfg:   cfg,
+		macro: macro,
+	}
+	service.startExpiryJob()
+	return service
+}
+
+// activeHolds returns the expiry of every unexpired hold among estateIDs.
+// Holds are read from the database on each call so that every replica sees
+// the changes made on the others; the partial unique index keeps it cheap.
+func (s *ReservationService) activeHolds(estateIDs []int) (map[int]time.Time, error) {
+	holds := make(map[int]time.Time)
+	if len(estateIDs) == 0 {
+		return holds, nil
+	}
+	var items []models.Reservation
+	if err := database.DB.Select("estate_id", "expires_at").
+		Where("status = ? AND expires_at > ? AND estate_id IN ?", models.ReservationActive, time.Now(), estateIDs).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		holds[item.EstateID] = item.ExpiresAt
+	}
+	return holds, nil
+}
+
+// MarkReservedEstates returns copies of the estates with "reserved" and
+// "reserved_until" fields set from the active holds.
+func (s *ReservationService) MarkReservedEstates(estates []map[string]any) []map[string]any {
+	ids := make([]int, 0, len(estates))
+	for _, item := range estates {
+		if id, ok := getNumberField(item, "id"); ok {
+			ids = append(ids, int(id))
+		}
+	}
+	holds, err := s.activeHolds(ids)
+	if err != nil {
+		log.Printf("[Reservations] failed to load active holds: %v", err)
+	}
+
+	out := make([]map[string]any, 0, len(estates))
+	for _, item := range estates {
+		marked := make(map[string]any, len(item)+2)
+		for k, v := range item {
+			marked[k] = v
+		}
+
+		marked["reserved"] = false
+		if id, ok := getNumberField(item, "id"); ok {
+			if until, held := holds[int(id)]; held {
+				marked["reserved"] = true
+				marked["reserved_until"] = until
+			}
+		}
+		out = append(out, marked)
+	}
+	return out
+}
+
+// holdDuration clamps the requested hours to the configured limits.
+func (s *ReservationService) holdDuration(hours int) (time.Duration, error) {
+	if hours <= 0 {
+		hours = s.cfg.ReservationDefaultHours
+	}
+	if hours > s.cfg.ReservationMaxHours {
+		return 0, fmt.Errorf("hold must not exceed %d hours", s.cfg.ReservationMaxHours)
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// Create places a hold on an estate.
+func (s *ReservationService) Create(estateID, hours int, createdBy string, submissionID *uint, notes string) (*models.Reservation, error) {
+	duration, err := s.holdDuration(hours)
+	if err != nil {
+		return nil, err
+	}
+
+	item := models.Reservation{
+		EstateID:     estateID,
+		Status:       models.ReservationActive,
+		ExpiresAt:    time.Now().Add(duration),
+		CreatedBy:    createdBy,
+		SubmissionID: submissionID,
+		Notes:        strings.TrimSpace(notes),
+	}
+
+	// A hold past its expiry that the job has not picked up yet still
+	// occupies the unique index, so it is expired here first.
+	var stale []models.Reservation
+	if err := database.DB.
+		Where("estate_id = ? AND status = ? AND expires_at <= ?", estateID, models.ReservationActive, time.Now()).
+		Find(&stale).Error; err != nil {
+		return nil, err
+	}
+	for i := range stale {
+		s.expire(&stale[i])
+	}
+
+	// The partial unique index on active holds settles concurrent requests
+	if err := database.DB.Create(&item).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrEstateAlreadyReserved
+		}
+		return nil, err
+	}
+
+	s.afterChange(&item, "создана")
+	return &item, nil
+}
+
+// Extend moves the expiry of an active hold, counting from now.
+func (s *ReservationService) Extend(id uint, hours int, by string) (*models.Reservation, error) {
+	duration, err := s.holdDuration(hours)
+	if err != nil {
+		return nil, err
+	}
+
+	var item models.Reservation
+	if err := database.DB.First(&item, id).Error; err != nil {
+		return nil, err
+	}
+	if item.Status != models.ReservationActive {
+		return nil, ErrReservationNotActive
+	}
+
+	item.ExpiresAt = time.Now().Add(duration)
+	result := database.DB.Model(&models.Reservation{}).
+		Where("id = ? AND status = ?", item.ID, models.ReservationActive).
+		Update("expires_at", item.ExpiresAt)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrReservationNotActive
+	}
+
+	s.afterChange(&item, "продлена ("+by+")")
+	return &item, nil
+}
+
+// Release ends an active hold early.
+func (s *ReservationService) Release(id uint, by string) (*models.Reservation, error) {
+	var item models.Reservation
+	if err := database.DB.First(&item, id).Error; err != nil {
+		return nil, err
+	}
+	if item.Status != models.ReservationActive {
+		return nil, ErrReservationNotActive
+	}
+
+	if err := s.finish(&item, models.ReservationReleased, by); err != nil {
+		return nil, err
+	}
+
+	s.afterChange(&item, "снята ("+by+")")
+	return &item, nil
+}
+
+// finish ends a hold that is still active. When a release and the expiry
+// job (possibly on another replica) race, only one of them wins; the other
+// gets ErrReservationNotActive and must not report the change again.
+func (s *ReservationService) finish(item *models.Reservation, status, by string) error {
+	now := time.Now()
+	result := database.DB.Model(&models.Reservation{}).
+		Where("id = ? AND status = ?", item.ID, models.ReservationActive).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"released_at": &now,
+			"released_by": by,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReservationNotActive
+	}
+	item.Status = status
+	item.ReleasedAt = &now
+	item.ReleasedBy = by
+	return nil
+}
+
+func (s *ReservationService) startExpiryJob() {
+	go func() {
+		ticker := time.NewTicker(reservationExpiryInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.expireDue()
+		}
+	}()
+}
+
+// expireDue auto-releases holds whose time is up.
+func (s *ReservationService) expireDue() {
+	var due []models.Reservation
+	if err := database.DB.
+		Where("status = ? AND expires_at <= ?", models.ReservationActive, time.Now()).
+		Find(&due).Error; err != nil {
+		log.Printf("[Reservations] failed to query expired holds: %v", err)
+		return
+	}
+
+	for i := range due {
+		s.expire(&due[i])
+	}
+}
+
+// expire auto-releases one hold unless someone else already ended it.
+func (s *ReservationService) expire(item *models.Reservation) {
+	if err := s.finish(item, models.ReservationExpired, "system"); err != nil {
+		if !errors.Is(err, ErrReservationNotActive) {
+			log.Printf("[Reservations] failed to expire reservation #%d: %v", item.ID, err)
+		}
+		return
+	}
+	s.afterChange(item, "истекла")
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// afterChange reports the new state to MacroCRM.
+func (s *ReservationService) afterChange(item *models.Reservation, event string) {
+	snapshot := *item
+	go s.syncToMacro(snapshot, event)
+}
+
+// syncToMacro sends the reservation state to MacroCRM as a request against the
+// estate, using the linked client's contacts when there is a submission.
+func (s *ReservationService) syncToMacro(item models.Reservation, event string) {
+	if s.macro == nil {
+		return
+	}
+
+	name := item.CreatedBy
+	phone := ""
+	email := ""
+	if item.SubmissionID != nil {
+		var submission models.ContactSubmission
+		if err := database.DB.First(&submission, *item.SubmissionID).Error; err == nil {
+			name = submission.Name
+			phone = submission.Phone
+			email = submission.Email
+		}
+	}
+
+	message := fmt.Sprintf(
+		"Бронь #%d %s. Статус: %s. До: %s. Менеджер: %s",
+		item.ID,
+		event,
+		item.Status,
+		item.ExpiresAt.Format("02.01.2006 15:04"),
+		item.CreatedBy,
+	)
+	if item.Notes != "" {
+		message += ". " + item.Notes
+	}
+
+	estateID := item.EstateID
+	updates := map[string]interface{}{}
+	if _, err := s.macro.SendRequest("callback", name, phone, email, message, &estateID); err != nil {
+		log.Printf("[MacroCRM] Failed to sync reservation #%d: %v", item.ID, err)
+		updates["macro_error"] = err.Error()
+	} else {
+		log.Printf("[MacroCRM] Reservation #%d synced (%s)", item.ID, event)
+		updates["macro_synced_at"] = time.Now()
+		updates["macro_error"] = ""
+	}
+
+	if err := database.DB.Model(&models.Reservation{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+		log.Printf("[Reservations] failed to store sync state for #%d: %v", item.ID, err)
+	}
+}
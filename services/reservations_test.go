@@ -0,0 +1,37 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"testing"
+)
+
+func TestReservationsVisibleOnEveryReplica(t *testing.T) {
+	useTestDB(t, &models.ContactSubmission{}, &models.Reservation{})
+	t.Cleanup(func() { database.DB.Where("estate_id IN ?", []int{9101, 9102}).Delete(&models.Reservation{}) })
+
+	cfg := &config.Config{ReservationDefaultHours: 24, ReservationMaxHours: 72}
+	first := &ReservationService{cfg: cfg}
+	second := &ReservationService{cfg: cfg}
+	estates := []map[string]any{{"id": 9101.0}, {"id": 9102.0}}
+
+	item, err := first.Create(9101, 0, "manager", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	marked := second.MarkReservedEstates(estates)
+	if marked[0]["reserved"] != true || marked[1]["reserved"] != false {
+		t.Fatalf("hold made on another replica: %v", marked)
+	}
+	if _, err := second.Create(9101, 0, "manager", nil, ""); err != ErrEstateAlreadyReserved {
+		t.Fatalf("second hold: got %v", err)
+	}
+
+	if _, err := first.Release(item.ID, "manager"); err != nil {
+		t.Fatal(err)
+	}
+	if marked := second.MarkReservedEstates(estates); marked[0]["reserved"] != false {
+		t.Fatalf("hold released on another replica: %v", marked)
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const resumableCleanupInterval = time.Hour
+
+var (
+	ErrUploadNotFound         = errors.New("upload not found")
+	ErrUploadOffsetMismatch   = errors.New("upload offset does not match")
+	ErrUploadTooLarge         = errors.New("upload exceeds its declared length")
+	ErrUploadChecksumMismatch = errors.New("checksum mismatch")
+	ErrUploadCompleted        = errors.New("upload already completed")
+	ErrUploadFailed           = errors.New("upload could not be completed")
+)
+
+// ChecksumAlgorithms are the Upload-Checksum algorithms accepted for chunks.
+var ChecksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// ChunkChecksum is the expected digest of one chunk.
+type ChunkChecksum struct {
+	Algorithm string
+	Sum       []byte
+}
+
+// ResumableUploadService implements the storage side of the tus protocol.
+// Chunks live in the storage backend, so any instance can take the next one.
+type ResumableUploadService struct {
+	cfg     *config.Config
+	storage *StorageService
+}
+
+func NewResumableUploadService(cfg *config.Config, storage *StorageService) *ResumableUploadService {
+	service := &ResumableUploadService{cfg: cfg, storage: storage}
+	service.startCleanupJob()
+	return service
+}
+
+// MaxSize is the largest file a resumable upload may declare.
+func (s *ResumableUploadService) MaxSize() int64 {
+	return int64(s.cfg.ResumableMaxSizeMB) * 1024 * 1024
+}
+
+func chunkKey(id, part string) string {
+	return fmt.Sprintf("_resumable/%s/%s", id, part)
+}
+
+// newPartName names a chunk written at offset. Two requests sending the
+// same offset get different names, so the one that loses the race cannot
+// remove the winner's chunk.
+func newPartName(offset int64) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%020d-%s", offset, hex.EncodeToString(buf)), nil
+}
+
+// Create starts an upload of length bytes. checksum is the optional SHA-256
+// (hex) the assembled file must match.
+func (s *ResumableUploadService) Create(length int64, filename, contentType, checksum, createdBy string) (*models.UploadSession, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("upload length required")
+	}
+	if length > s.MaxSize() {
+		return nil, fmt.Errorf("file too large, max size is %dMB", s.cfg.ResumableMaxSizeMB)
+	}
+	if filename == "" {
+		return nil, fmt.Errorf("missing filename")
+	}
+	if err := s.storage.CheckAllowed(filename, contentType); err != nil {
+		return nil, err
+	}
+	if checksum != "" {
+		if sum, err := hex.DecodeString(checksum); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("checksum must be a hex SHA-256")
+		}
+	}
+
+	session := models.UploadSession{
+		ID:          uuid.New().String(),
+		Filename:    filename,
+		ContentType: contentType,
+		Length:      length,
+		Checksum:    checksum,
+		CreatedBy:   createdBy,
+		ExpiresAt:   time.Now().Add(s.cfg.ResumableUploadTTL),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Get returns an upload that has not expired.
+func (s *ResumableUploadService) Get(id string) (*models.UploadSession, error) {
+	var session models.UploadSession
+	if err := database.DB.Where("id = ? AND expires_at > ?", id, time.Now()).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// WriteChunk appends size bytes at offset. The chunk that completes the
+// upload also assembles the file; its result is returned alongside.
+func (s *ResumableUploadService) WriteChunk(id string, offset int64, size int64, body io.Reader, checksum *ChunkChecksum) (*models.UploadSession, *StoredFile, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.CompletedAt != nil || session.Error != "" {
+		return nil, nil, ErrUploadCompleted
+	}
+	if offset != session.Received {
+		return nil, nil, ErrUploadOffsetMismatch
+	}
+	if size < 0 || offset+size > session.Length {
+		return nil, nil, ErrUploadTooLarge
+	}
+	if size == 0 {
+		return session, nil, nil
+	}
+
+	var hasher hash.Hash
+	if checksum != nil {
+		hasher = ChecksumAlgorithms[checksum.Algorithm]()
+		body = io.TeeReader(body, hasher)
+	}
+
+	part, err := newPartName(offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := chunkKey(id, part)
+	var written byteCounter
+	if err := s.storage.Backend().Put(key, io.TeeReader(body, &written), size, "application/octet-stream"); err != nil {
+		s.storage.DeleteFile(key)
+		return nil, nil, err
+	}
+	if int64(written) != size {
+		s.storage.DeleteFile(key)
+		return nil, nil, fmt.Errorf("chunk ended after %d of %d bytes", written, size)
+	}
+	if hasher != nil && !bytes.Equal(hasher.Sum(nil), checksum.Sum) {
+		s.storage.DeleteFile(key)
+		return nil, nil, ErrUploadChecksumMismatch
+	}
+
+	// Only advance if nobody else wrote this offset meanwhile
+	parts := append(session.Parts, part)
+	partsJSON, _ := json.Marshal(parts)
+	expiresAt := time.Now().Add(s.cfg.ResumableUploadTTL)
+	result := database.DB.Model(&models.UploadSession{}).
+		Where("id = ? AND received = ?", id, offset).
+		Updates(map[string]interface{}{
+			"received":   offset + size,
+			"parts":      string(partsJSON),
+			"expires_at": expiresAt,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		s.storage.DeleteFile(key)
+		if result.Error != nil {
+			return nil, nil, result.Error
+		}
+		return nil, nil, ErrUploadOffsetMismatch
+	}
+	session.Received = offset + size
+	session.Parts = parts
+	session.ExpiresAt = expiresAt
+
+	if session.Received < session.Length {
+		return session, nil, nil
+	}
+	stored, err := s.assemble(session)
+	return session, stored, err
+}
+
+// assemble streams the chunks in order through the normal upload pipeline.
+func (s *ResumableUploadService) assemble(session *models.UploadSession) (*StoredFile, error) {
+	defer s.deleteChunks(session)
+
+	reader := &chunkReader{backend: s.storage.Backend(), id: session.ID, parts: session.Parts}
+	defer reader.Close()
+
+	whole := sha256.New()
+	body := io.TeeReader(reader, whole)
+	stored, err := s.storage.UploadAssembled(session.Filename, session.ContentType, session.Length, body)
+	if err == nil && session.Checksum != "" {
+		// Image decoding may stop before the end; hash the rest too
+		if _, err = io.Copy(io.Discard, body); err == nil && hex.EncodeToString(whole.Sum(nil)) != session.Checksum {
+			err = ErrUploadChecksumMismatch
+		}
+		if err != nil {
+			s.storage.discard(stored)
+		}
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"completed_at": now}
+	if err != nil {
+		message := err.Error()
+		if len(message) > 255 {
+			message = message[:255]
+		}
+		updates["error"] = message
+	} else {
+		updates["result_path"] = stored.Path
+		session.ResultPath = stored.Path
+	}
+	session.CompletedAt = &now
+	if dbErr := database.DB.Model(session).Updates(updates).Error; dbErr != nil {
+		log.Printf("[Uploads] failed to mark upload %s completed: %v", session.ID, dbErr)
+	}
+
+	if err != nil {
+		var infected *InfectedError
+		if errors.Is(err, ErrUploadChecksumMismatch) || errors.As(err, &infected) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUploadFailed, err)
+	}
+	return stored, nil
+}
+
+// Terminate cancels an upload and removes its chunks.
+func (s *ResumableUploadService) Terminate(id string) error {
+	session, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	s.deleteChunks(session)
+	return database.DB.Delete(session).Error
+}
+
+func (s *ResumableUploadService) deleteChunks(session *models.UploadSession) {
+	for _, part := range session.Parts {
+		if err := s.storage.DeleteFile(chunkKey(session.ID, part)); err != nil {
+			log.Printf("[Uploads] failed to delete chunk %s of %s: %v", part, session.ID, err)
+		}
+	}
+}
+
+func (s *ResumableUploadService) startCleanupJob() {
+	go func() {
+		ticker := time.NewTicker(resumableCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.purgeExpired()
+		}
+	}()
+}
+
+// purgeExpired removes abandoned uploads and the records of finished ones.
+func (s *ResumableUploadService) purgeExpired() {
+	var sessions []models.UploadSession
+	if err := database.DB.Where("expires_at < ?", time.Now()).Limit(500).Find(&sessions).Error; err != nil {
+		log.Printf("[Uploads] failed to find expired uploads: %v", err)
+		return
+	}
+	for i := range sessions {
+		if sessions[i].CompletedAt == nil {
+			s.deleteChunks(&sessions[i])
+		}
+		database.DB.Delete(&sessions[i])
+	}
+	if len(sessions) > 0 {
+		log.Printf("[Uploads] removed %d expired uploads", len(sessions))
+	}
+}
+
+// chunkReader reads the chunks of an upload back to back, opening each
+// only when the previous one is used up.
+type chunkReader struct {
+	backend Storage
+	id      string
+	parts   []string
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			reader, err := r.backend.Get(chunkKey(r.id, r.parts[0]))
+			if err != nil {
+				return 0, err
+			}
+			r.current = reader
+			r.parts = r.parts[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedReader signals when it is first read and then waits for release, so
+// both requests are past the offset check before either stores its chunk.
+type gatedReader struct {
+	r       io.Reader
+	started chan<- struct{}
+	release <-chan struct{}
+	once    sync.Once
+}
+
+func (g *gatedReader) Read(p []byte) (int, error) {
+	g.once.Do(func() {
+		g.started <- struct{}{}
+		<-g.release
+	})
+	return g.r.Read(p)
+}
+
+func TestWriteChunkSameOffsetKeepsWinnersChunk(t *testing.T) {
+	useTestDB(t, &models.UploadSession{})
+	dir := t.TempDir()
+	service := &ResumableUploadService{
+		cfg:     &config.Config{ResumableUploadTTL: time.Hour},
+		storage: NewStorageService(&config.Config{}, NewLocalStorage(dir), nil),
+	}
+	session := models.UploadSession{ID: "race-test", Filename: "video.mp4", Length: 8, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Delete(&session) })
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errs := make(chan error, 2)
+	for _, data := range []string{"aaaa", "bbbb"} {
+		body := &gatedReader{r: bytes.NewReader([]byte(data)), started: started, release: release}
+		go func() {
+			_, _, err := service.WriteChunk(session.ID, 0, 4, body, nil)
+			errs <- err
+		}()
+	}
+	<-started
+	<-started
+	close(release)
+
+	var failed int
+	for i := 0; i < 2; i++ {
+		if err := <-errs; errors.Is(err, ErrUploadOffsetMismatch) {
+			failed++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("%d requests lost the race, want 1", failed)
+	}
+
+	stored, err := service.Get(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Received != 4 || len(stored.Parts) != 1 {
+		t.Fatalf("received %d in %d parts", stored.Received, len(stored.Parts))
+	}
+	if _, err := service.storage.Backend().Stat(chunkKey(session.ID, stored.Parts[0])); err != nil {
+		t.Fatalf("winner's chunk is gone: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "_resumable", session.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d chunks left on disk, want 1", len(entries))
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bufio"
+	"eman-backend/config"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamd reads INSTREAM data in chunks prefixed with their length
+const clamdChunkSize = 64 * 1024
+
+var ErrScanUnavailable = errors.New("malware scanner unavailable, try again later")
+
+// ScanResult is the verdict on one file.
+type ScanResult struct {
+	Infected  bool
+	Signature string // name of the detected malware
+}
+
+// Scanner checks uploaded files for malware.
+type Scanner interface {
+	Scan(r io.Reader) (*ScanResult, error)
+}
+
+// InfectedError is returned for an upload the scanner flagged. The file has
+// been moved to quarantine; File describes it for the media library.
+type InfectedError struct {
+	File *StoredFile
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("malware detected: %s", e.File.ScanSignature)
+}
+
+// NewScanner builds the configured scanner; nil when scanning is off.
+func NewScanner(cfg *config.Config) Scanner {
+	if cfg.MalwareScanner == "clamav" {
+		return NewClamAVScanner(cfg.ClamAVAddress, cfg.ClamAVTimeout)
+	}
+	return nil
+}
+
+// ClamAVScanner streams files to clamd with the INSTREAM command.
+type ClamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner connects to clamd at host:port or unix:/path.
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	network := "tcp"
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	return &ClamAVScanner{network: network, address: address, timeout: timeout}
+}
+
+func (s *ClamAVScanner) Scan(r io.Reader) (*ScanResult, error) {
+	conn, err := net.DialTimeout(s.network, s.address, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+
+	// clamd may hang up early, e.g. past its StreamMaxLength; its reply
+	// then explains why, so a failed write still goes on to read it
+	readErr, writeErr := s.stream(conn, r)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if writeErr == nil {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+		_, writeErr = conn.Write([]byte{0, 0, 0, 0})
+	}
+
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		if writeErr != nil {
+			return nil, fmt.Errorf("clamd: %w", writeErr)
+		}
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// stream sends r as INSTREAM chunks, telling failures to read the file
+// apart from failures to write to clamd.
+func (s *ClamAVScanner) stream(conn net.Conn, r io.Reader) (readErr, writeErr error) {
+	chunk := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk, uint32(n))
+			conn.SetDeadline(time.Now().Add(s.timeout))
+			if _, err := conn.Write(chunk[:4+n]); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		if err != nil {
+			return err, nil
+		}
+	}
+}
+
+// parseClamdReply reads "stream: OK", "stream: <name> FOUND" or "<why> ERROR".
+func parseClamdReply(reply string) (*ScanResult, error) {
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &ScanResult{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	case strings.HasSuffix(verdict, " ERROR"):
+		return nil, fmt.Errorf("clamd: %s", strings.TrimSuffix(verdict, " ERROR"))
+	default:
+		return nil, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+}
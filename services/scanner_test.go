@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"eman-backend/config"
+	"eman-backend/models"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd serves the INSTREAM command. reply decides the answer from the
+// streamed bytes; with hangupAfter > 0 it answers and hangs up after that
+// many bytes, as clamd does past StreamMaxLength.
+type fakeClamd struct {
+	reply       func(data []byte) string
+	hangupAfter int
+}
+
+func (f *fakeClamd) start(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if command, err := reader.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+		return
+	}
+	var data []byte
+	for {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return
+		}
+		data = append(data, chunk...)
+		if f.hangupAfter > 0 && len(data) >= f.hangupAfter {
+			break
+		}
+	}
+	if reply := f.reply(data); reply != "" {
+		conn.Write([]byte(reply + "\x00"))
+	}
+}
+
+func TestClamAVScanner(t *testing.T) {
+	clamd := &fakeClamd{reply: func(data []byte) string {
+		switch {
+		case bytes.Contains(data, []byte("EICAR")):
+			return "stream: Eicar-Test-Signature FOUND"
+		case bytes.Contains(data, []byte("broken")):
+			return "stream: Can't allocate memory ERROR"
+		case bytes.Contains(data, []byte("silent")):
+			return ""
+		}
+		return "stream: OK"
+	}}
+	scanner := NewClamAVScanner(clamd.start(t), time.Second)
+
+	result, err := scanner.Scan(strings.NewReader("harmless brochure"))
+	if err != nil || result.Infected {
+		t.Fatalf("clean file: %+v, %v", result, err)
+	}
+
+	// Bigger than one INSTREAM chunk, with the signature in the second
+	infected := append(bytes.Repeat([]byte{'a'}, clamdChunkSize+10), "EICAR"...)
+	result, err = scanner.Scan(bytes.NewReader(infected))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("infected file: %+v, %v", result, err)
+	}
+
+	if _, err := scanner.Scan(strings.NewReader("broken")); err == nil || !strings.Contains(err.Error(), "Can't allocate memory") {
+		t.Fatalf("clamd error: %v", err)
+	}
+	if _, err := scanner.Scan(strings.NewReader("silent")); err == nil {
+		t.Fatal("no reply taken as a verdict")
+	}
+}
+
+func TestClamAVScannerEarlyHangup(t *testing.T) {
+	clamd := &fakeClamd{
+		reply:       func([]byte) string { return "INSTREAM size limit exceeded. ERROR" },
+		hangupAfter: 1,
+	}
+	scanner := NewClamAVScanner(clamd.start(t), time.Second)
+
+	// Far more than the socket buffers hold, so writing fails after the hangup
+	_, err := scanner.Scan(bytes.NewReader(make([]byte, 32*1024*1024)))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Fatalf("got %v, want clamd's reason", err)
+	}
+}
+
+func TestClamAVScannerUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewClamAVScanner(address, time.Second).Scan(strings.NewReader("x")); err == nil {
+		t.Fatal("scan without clamd succeeded")
+	}
+}
+
+// stubScanner returns a fixed verdict and records what the file looked
+// like from the public upload root while it was scanned.
+type stubScanner struct {
+	result *ScanResult
+	err    error
+	dir    string
+	public []string
+}
+
+func (s *stubScanner) Scan(r io.Reader) (*ScanResult, error) {
+	io.Copy(io.Discard, r)
+	filepath.WalkDir(s.dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(s.dir, path)
+			if !IsReservedKey(filepath.ToSlash(rel)) {
+				s.public = append(s.public, rel)
+			}
+		}
+		return nil
+	})
+	return s.result, s.err
+}
+
+func newScanningStorage(t *testing.T, scanner *stubScanner, failOpen bool) (*StorageService, string) {
+	t.Helper()
+	dir := t.TempDir()
+	scanner.dir = dir
+	cfg := &config.Config{MaxUploadSizeMB: 10, ScanMaxSizeMB: 1, ScanFailOpen: failOpen}
+	return NewStorageService(cfg, NewLocalStorage(dir), scanner), dir
+}
+
+// files lists every file under dir, slash-separated.
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	var out []string
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return out
+}
+
+func TestUploadIsPublishedOnlyAfterCleanScan(t *testing.T) {
+	scanner := &stubScanner{result: &ScanResult{}}
+	storage, dir := newScanningStorage(t, scanner, false)
+
+	stored, err := storage.UploadStream("brochure.pdf", "application/pdf", 9, strings.NewReader("%PDF-1.7\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scanner.public) != 0 {
+		t.Fatalf("public before the scan finished: %v", scanner.public)
+	}
+	if stored.ScanStatus != models.ScanClean || IsReservedKey(stored.Path) {
+		t.Fatalf("stored %s as %s", stored.Path, stored.ScanStatus)
+	}
+	if got := files(t, dir); len(got) != 1 || got[0] != stored.Path {
+		t.Fatalf("files on disk: %v", got)
+	}
+}
+
+func TestInfectedUploadGoesStraightToQuarantine(t *testing.T) {
+	scanner := &stubScanner{result: &ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}}
+	storage, dir := newScanningStorage(t, scanner, false)
+
+	_, err := storage.UploadStream("archive.zip", "application/zip", 4, strings.NewReader("PK\x03\x04"))
+	var infected *InfectedError
+	if !errors.As(err, &infected) {
+		t.Fatalf("got %v, want InfectedError", err)
+	}
+	if got := files(t, dir); len(got) != 1 || got[0] != QuarantineKey(infected.File.Path) {
+		t.Fatalf("files on disk: %v", got)
+	}
+}
+
+func TestUploadRefusedWhenScannerUnavailable(t *testing.T) {
+	scanner := &stubScanner{err: errors.New("connection refused")}
+	storage, dir := newScanningStorage(t, scanner, false)
+
+	if _, err := storage.UploadStream("notes.txt", "text/plain", 5, strings.NewReader("notes")); !errors.Is(err, ErrScanUnavailable) {
+		t.Fatalf("got %v, want ErrScanUnavailable", err)
+	}
+	if got := files(t, dir); len(got) != 0 {
+		t.Fatalf("files left on disk: %v", got)
+	}
+
+	// SCAN_FAIL_OPEN keeps the upload, marked as unscanned
+	storage, _ = newScanningStorage(t, scanner, true)
+	stored, err := storage.UploadStream("notes.txt", "text/plain", 5, strings.NewReader("notes"))
+	if err != nil || stored.ScanStatus != models.ScanFailed || IsReservedKey(stored.Path) {
+		t.Fatalf("fail-open: %+v, %v", stored, err)
+	}
+}
+
+func TestUploadTooLargeToScanIsRefused(t *testing.T) {
+	scanner := &stubScanner{result: &ScanResult{}}
+	storage, dir := newScanningStorage(t, scanner, false)
+	data := append([]byte("%PDF-1.7\n"), make([]byte, 2*1024*1024)...)
+
+	// Refused up front when the size is declared...
+	if _, err := storage.UploadStream("big.pdf", "application/pdf", int64(len(data)), bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "scanned") {
+		t.Fatalf("oversized upload: %v", err)
+	}
+	// ...and after storing when it is not
+	if _, err := storage.saveFromReader(bytes.NewReader(data), -1, "big.pdf", "application/pdf"); err == nil || !strings.Contains(err.Error(), "scanned") {
+		t.Fatalf("oversized upload of unknown size: %v", err)
+	}
+	if got := files(t, dir); len(got) != 0 {
+		t.Fatalf("files left on disk: %v", got)
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+const (
+	sessionCleanupInterval = time.Hour
+	// Revoked and expired sessions stay listed for a while for auditing.
+	sessionRetention = 30 * 24 * time.Hour
+)
+
+// SessionService issues and rotates server-side refresh tokens.
+type SessionService struct {
+	cfg *config.Config
+}
+
+func NewSessionService(cfg *config.Config) *SessionService {
+	service := &SessionService{cfg: cfg}
+	service.startCleanupJob()
+	return service
+}
+
+func (s *SessionService) refreshTTL() time.Duration {
+	return time.Duration(s.cfg.RefreshExpiry) * time.Minute
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// issueToken stores a fresh refresh token for the session and returns its raw value.
+func issueToken(tx *gorm.DB, sessionID uint, expiresAt time.Time) (string, error) {
+	raw, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	token := models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashRefreshToken(raw),
+		ExpiresAt: expiresAt,
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Start opens a new session for a successful login.
+func (s *SessionService) Start(adminID uint, userAgent, ip string) (string, *models.AdminSession, error) {
+	now := time.Now()
+	session := models.AdminSession{
+		AdminUserID: adminID,
+		UserAgent:   userAgent,
+		IPAddress:   ip,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(s.refreshTTL()),
+	}
+
+	var raw string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		raw, err = issueToken(tx, session.ID, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return raw, &session, nil
+}
+
+// Rotate exchanges a refresh token for a new one. Presenting a token that
+// was already used revokes the whole session, since either the client or
+// an attacker holds a stolen copy.
+func (s *SessionService) Rotate(raw, userAgent, ip string) (string, *models.AdminSession, error) {
+	if raw == "" {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+
+	var token models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", hashRefreshToken(raw)).First(&token).Error; err != nil {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+
+	var session models.AdminSession
+	if err := database.DB.First(&session, token.SessionID).Error; err != nil {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+	if !session.Active() || !token.ExpiresAt.After(time.Now()) {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return "", nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := s.Revoke(session.ID, models.SessionRevokedTokenReuse); err != nil {
+			log.Printf("[Sessions] failed to revoke session #%d after token reuse: %v", session.ID, err)
+		}
+		log.Printf("[Sessions] refresh token reuse detected, session #%d revoked", session.ID)
+		return "", nil, ErrRefreshTokenReused
+	}
+
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(s.refreshTTL())
+	session.IPAddress = ip
+	if userAgent != "" {
+		session.UserAgent = userAgent
+	}
+
+	var next string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"ip_address":   session.IPAddress,
+			"user_agent":   session.UserAgent,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		next, err = issueToken(tx, session.ID, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return next, &session, nil
+}
+
+// SessionForToken returns the session a refresh token belongs to.
+func (s *SessionService) SessionForToken(raw string) (*models.AdminSession, error) {
+	var token models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", hashRefreshToken(raw)).First(&token).Error; err != nil {
+		return nil, err
+	}
+	var session models.AdminSession
+	if err := database.DB.First(&session, token.SessionID).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ActiveSessions lists the admin's usable sessions, most recent first.
+func (s *SessionService) ActiveSessions(adminID uint) ([]models.AdminSession, error) {
+	var items []models.AdminSession
+	err := database.DB.
+		Where("admin_user_id = ? AND revoked_at IS NULL AND expires_at > ?", adminID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&items).Error
+	return items, err
+}
+
+// Revoke ends a single session.
+func (s *SessionService) Revoke(sessionID uint, reason string) error {
+	return database.DB.Model(&models.AdminSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// RevokeAll ends every session of an admin.
+func (s *SessionService) RevokeAll(adminID uint, reason string) error {
+	return database.DB.Model(&models.AdminSession{}).
+		Where("admin_user_id = ? AND revoked_at IS NULL", adminID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+func (s *SessionService) startCleanupJob() {
+	go func() {
+		ticker := time.NewTicker(sessionCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.cleanup()
+		}
+	}()
+}
+
+// cleanup drops expired refresh tokens and long-dead sessions.
+func (s *SessionService) cleanup() {
+	now := time.Now()
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+		log.Printf("[Sessions] failed to purge refresh tokens: %v", err)
+	}
+
+	cutoff := now.Add(-sessionRetention)
+	var stale []uint
+	database.DB.Model(&models.AdminSession{}).
+		Where("expires_at <= ? OR revoked_at <= ?", cutoff, cutoff).
+		Pluck("id", &stale)
+	if len(stale) == 0 {
+		return
+	}
+	database.DB.Where("session_id IN ?", stale).Delete(&models.RefreshToken{})
+	if err := database.DB.Delete(&models.AdminSession{}, stale).Error; err != nil {
+		log.Printf("[Sessions] failed to purge sessions: %v", err)
+	}
+}
@@ -2,30 +2,48 @@ package services
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"eman-backend/config"
+	"eman-backend/models"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/chai2010/webp"
 	"github.com/google/uuid"
+	_ "golang.org/x/image/tiff"
 )
 
 type StorageService struct {
-	cfg *config.Config
+	cfg     *config.Config
+	backend Storage
+	scanner Scanner // nil when scanning is off
 }
 
-func NewStorageService(cfg *config.Config) *StorageService {
-	return &StorageService{cfg: cfg}
+func NewStorageService(cfg *config.Config, backend Storage, scanner Scanner) *StorageService {
+	return &StorageService{cfg: cfg, backend: backend, scanner: scanner}
+}
+
+// Backend returns the storage backend files are written to.
+func (s *StorageService) Backend() Storage {
+	return s.backend
+}
+
+// URL returns the public URL of an uploaded file.
+func (s *StorageService) URL(relativePath string) string {
+	return s.backend.URL(relativePath)
 }
 
 // AllowedExtensions for file uploads
@@ -64,67 +82,42 @@ var AllowedExtensions = map[string]bool{
 	".7z":   true,
 }
 
-var AllowedMimeTypes = map[string]bool{
-	"image/jpeg":         true,
-	"image/jpg":          true,
-	"image/png":          true,
-	"image/gif":          true,
-	"image/webp":         true,
-	"image/bmp":          true,
-	"image/tiff":         true,
-	"video/mp4":          true,
-	"video/webm":         true,
-	"video/quicktime":    true,
-	"application/mp4":    true,
-	"application/webm":   true,
-	"audio/mpeg":         true,
-	"audio/mp3":          true,
-	"audio/wav":          true,
-	"audio/x-wav":        true,
-	"audio/wave":         true,
-	"audio/ogg":          true,
-	"audio/mp4":          true,
-	"audio/x-m4a":        true,
-	"audio/aac":          true,
-	"application/pdf":    true,
-	"application/msword": true,
-	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
-	"application/vnd.ms-excel": true,
-	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
-	"application/vnd.ms-powerpoint":                                             true,
-	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
-	"text/plain":      true,
-	"text/csv":        true,
-	"text/rtf":        true,
-	"application/rtf": true,
-	"application/vnd.oasis.opendocument.text":         true,
-	"application/vnd.oasis.opendocument.spreadsheet":  true,
-	"application/vnd.oasis.opendocument.presentation": true,
-	"application/zip":              true,
-	"application/x-zip-compressed": true,
-	"application/vnd.rar":          true,
-	"application/x-rar-compressed": true,
-	"application/x-7z-compressed":  true,
-}
-
 var ConvertibleToWebP = map[string]bool{
 	".jpg":  true,
 	".jpeg": true,
 	".png":  true,
+	".tif":  true,
+	".tiff": true,
+}
+
+// StoredFile describes a file as it was written to storage.
+type StoredFile struct {
+	Path         string
+	OriginalName string
+	Size         int64
+	MimeType     string
+	Width        int
+	Height       int
+	Checksum     string // SHA-256, hex
+	Variants     []models.MediaVariant
+
+	ScanStatus    string
+	ScanSignature string
+	ScannedAt     *time.Time
 }
 
 // UploadFile saves an uploaded file to storage
-func (s *StorageService) UploadFile(file *multipart.FileHeader) (string, error) {
+func (s *StorageService) UploadFile(file *multipart.FileHeader) (*StoredFile, error) {
 	// Validate file size
 	maxFileSize := int64(s.cfg.MaxUploadSizeMB) * 1024 * 1024
 	if file.Size > maxFileSize {
-		return "", fmt.Errorf("file too large, max size is %dMB", s.cfg.MaxUploadSizeMB)
+		return nil, fmt.Errorf("file too large, max size is %dMB", s.cfg.MaxUploadSizeMB)
 	}
 
 	// Open source file
 	src, err := file.Open()
 	if err != nil {
-		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer src.Close()
 
@@ -133,161 +126,221 @@ func (s *StorageService) UploadFile(file *multipart.FileHeader) (string, error)
 		contentType = file.Header.Get("Content-Type")
 	}
 
-	return s.saveFromReader(src, file.Filename, contentType)
+	return s.saveFromReader(src, file.Size, file.Filename, contentType)
 }
 
 // UploadStream saves a streamed upload to storage.
-func (s *StorageService) UploadStream(filename string, contentType string, size int64, body io.Reader) (string, error) {
+func (s *StorageService) UploadStream(filename string, contentType string, size int64, body io.Reader) (*StoredFile, error) {
 	if filename == "" {
-		return "", fmt.Errorf("missing filename")
+		return nil, fmt.Errorf("missing filename")
 	}
 
 	maxFileSize := int64(s.cfg.MaxUploadSizeMB) * 1024 * 1024
 	if size <= 0 {
-		return "", fmt.Errorf("content length required")
+		return nil, fmt.Errorf("content length required")
 	}
 	if size > maxFileSize {
-		return "", fmt.Errorf("file too large, max size is %dMB", s.cfg.MaxUploadSizeMB)
+		return nil, fmt.Errorf("file too large, max size is %dMB", s.cfg.MaxUploadSizeMB)
 	}
 
-	return s.saveFromReader(body, filename, contentType)
+	return s.saveFromReader(body, size, filename, contentType)
 }
 
-// DeleteFile removes a file from storage
-func (s *StorageService) DeleteFile(relativePath string) error {
-	fullPath := filepath.Join(s.cfg.UploadDir, relativePath)
-
-	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return nil // File doesn't exist, nothing to delete
+// UploadAssembled saves a file put together from resumable upload chunks.
+// It is bounded by RESUMABLE_MAX_SIZE_MB rather than the per-request limit.
+func (s *StorageService) UploadAssembled(filename string, contentType string, size int64, body io.Reader) (*StoredFile, error) {
+	if size > int64(s.cfg.ResumableMaxSizeMB)*1024*1024 {
+		return nil, fmt.Errorf("file too large, max size is %dMB", s.cfg.ResumableMaxSizeMB)
 	}
+	return s.saveFromReader(body, size, filename, contentType)
+}
 
-	return os.Remove(fullPath)
+// CheckAllowed rejects a file type before any of it is uploaded. The
+// content itself is verified once the file is stored.
+func (s *StorageService) CheckAllowed(filename string, contentType string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		ext = extensionFromContentType(normalizeContentType(contentType))
+	}
+	return checkExtension(ext)
 }
 
-// GetFilePath returns the full filesystem path for a relative path
-func (s *StorageService) GetFilePath(relativePath string) string {
-	return filepath.Join(s.cfg.UploadDir, relativePath)
+// DeleteFile removes a file from storage
+func (s *StorageService) DeleteFile(relativePath string) error {
+	return s.backend.Delete(relativePath)
 }
 
-func (s *StorageService) saveFromReader(reader io.Reader, originalName string, contentType string) (string, error) {
-	normalizedContentType := normalizeContentType(contentType)
+// Read ahead far enough for image headers, which carry the dimensions
+const imageHeaderPeek = 64 * 1024
 
-	buffered := bufio.NewReader(reader)
-	if normalizedContentType == "" {
-		if sniff, err := buffered.Peek(512); err == nil || len(sniff) > 0 {
-			normalizedContentType = normalizeContentType(http.DetectContentType(sniff))
-		}
+// saveFromReader stores an upload. With a scanner it is written to a
+// private staging key and only published once the scan comes back clean.
+func (s *StorageService) saveFromReader(reader io.Reader, size int64, originalName string, contentType string) (*StoredFile, error) {
+	if s.scanner == nil {
+		return s.store(reader, size, originalName, contentType, "")
+	}
+	if size > s.scanMaxSize() {
+		return nil, s.errScanTooLarge()
 	}
+	stored, err := s.store(reader, size, originalName, contentType, stagingPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.scan(stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
 
+// store writes an upload under prefix, which is empty for public files.
+func (s *StorageService) store(reader io.Reader, size int64, originalName string, contentType string, prefix string) (*StoredFile, error) {
+	buffered := bufio.NewReaderSize(reader, imageHeaderPeek)
+	header, _ := buffered.Peek(sniffLength)
+
+	// The extension decides the type and the content has to match it; the
+	// client's Content-Type only stands in for a missing extension
 	ext := strings.ToLower(filepath.Ext(originalName))
 	if ext == "" {
-		ext = extensionFromContentType(normalizedContentType)
+		ext = extensionFromContentType(normalizeContentType(contentType))
 	}
-
-	if !isAllowedType(ext, normalizedContentType) {
-		if ext == "" {
-			return "", fmt.Errorf("file type not allowed")
-		}
-		return "", fmt.Errorf("file type not allowed: %s", ext)
+	if ext == "" {
+		ext = extensionFromContentType(normalizeContentType(http.DetectContentType(header)))
 	}
-
-	now := time.Now()
-	uploadPath := filepath.Join(s.cfg.UploadDir, now.Format("2006/01"))
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	if err := checkContent(ext, header); err != nil {
+		return nil, err
 	}
+	contentType = ContentTypes[ext]
 
+	now := time.Now()
+	dir := prefix + now.Format("2006/01")
 	baseName := fmt.Sprintf("%s_%s", now.Format("20060102"), uuid.New().String()[:8])
 
-	if shouldConvertToWebP(ext, normalizedContentType) {
-		filename := baseName + ".webp"
-		fullPath := filepath.Join(uploadPath, filename)
-
-		if err := s.writeWebP(fullPath, buffered); err != nil {
-			return "", err
+	if ConvertibleToWebP[ext] {
+		var source io.Reader = buffered
+		orientation := 1
+		switch contentType {
+		case "image/jpeg":
+			head, _ := readJPEGHeader(buffered)
+			orientation = jpegOrientation(head)
+			source = io.MultiReader(bytes.NewReader(head), buffered)
+		case "image/tiff":
+			// The directory holding the orientation may sit anywhere in the file
+			data, err := io.ReadAll(io.LimitReader(buffered, maxVariantSourceSize+1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image: %w", err)
+			}
+			if len(data) > maxVariantSourceSize {
+				return nil, fmt.Errorf("tiff images are limited to %dMB", maxVariantSourceSize/1024/1024)
+			}
+			orientation = exifOrientation(data)
+			source = bytes.NewReader(data)
 		}
-
-		return filepath.Join(now.Format("2006/01"), filename), nil
+		stored, err := s.writeWebP(path.Join(dir, baseName+".webp"), source, orientation)
+		if err != nil {
+			return nil, err
+		}
+		stored.OriginalName = originalName
+		return stored, nil
 	}
 
-	if ext == "" {
-		return "", fmt.Errorf("missing file extension")
+	// WebPs are kept as uploaded, minus their EXIF and XMP
+	if ext == ".webp" {
+		data, err := io.ReadAll(io.LimitReader(buffered, maxVariantSourceSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		if len(data) > maxVariantSourceSize {
+			return nil, fmt.Errorf("webp images are limited to %dMB", maxVariantSourceSize/1024/1024)
+		}
+		if data, err = stripWebPMetadata(data); err != nil {
+			return nil, err
+		}
+		buffered = bufio.NewReaderSize(bytes.NewReader(data), imageHeaderPeek)
+		size = int64(len(data))
 	}
 
-	filename := baseName + ext
-	fullPath := filepath.Join(uploadPath, filename)
-	if err := writeStream(fullPath, buffered); err != nil {
-		return "", err
+	stored := &StoredFile{
+		Path:         path.Join(dir, baseName+ext),
+		OriginalName: originalName,
+		MimeType:     contentType,
 	}
-
-	return filepath.Join(now.Format("2006/01"), filename), nil
-}
-
-func (s *StorageService) writeWebP(fullPath string, reader io.Reader) error {
-	img, _, err := image.Decode(reader)
-	if err != nil {
-		return fmt.Errorf("failed to decode image: %w", err)
+	if strings.HasPrefix(contentType, "image/") {
+		header, _ := buffered.Peek(imageHeaderPeek)
+		if dims, _, err := image.DecodeConfig(bytes.NewReader(header)); err == nil {
+			stored.Width, stored.Height = dims.Width, dims.Height
+		}
 	}
 
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+	hasher := sha256.New()
+	var written byteCounter
+	sinks := []io.Writer{hasher, &written}
+
+	// GIFs and WebPs are kept as uploaded, so variants need a copy to decode
+	var raw *bytes.Buffer
+	if hasVariants(contentType) && size <= maxVariantSourceSize {
+		raw = &bytes.Buffer{}
+		sinks = append(sinks, raw)
 	}
-	defer dst.Close()
 
-	quality := s.cfg.WebPQuality
-	if quality <= 0 {
-		quality = 85
+	body := io.TeeReader(buffered, io.MultiWriter(sinks...))
+	if err := s.backend.Put(stored.Path, body, size, contentType); err != nil {
+		return nil, err
 	}
-	if quality > 100 {
-		quality = 100
+	stored.Size = int64(written)
+	stored.Checksum = hex.EncodeToString(hasher.Sum(nil))
+
+	if raw != nil {
+		if img, err := decodeStill(raw.Bytes()); err == nil {
+			stored.Variants = s.writeVariants(stored.Path, img)
+		} else if !errors.Is(err, ErrImageAnimated) {
+			log.Printf("[Storage] %s: no variants: %v", stored.Path, err)
+		}
 	}
 
-	options := &webp.Options{
-		Quality: float32(quality),
-	}
+	return stored, nil
+}
 
-	if s.cfg.WebPLossless {
-		options.Lossless = true
-	}
-	if s.cfg.WebPExact {
-		options.Exact = true
-	}
+// hasVariants reports whether uploads of contentType that are stored as-is get variants.
+func hasVariants(contentType string) bool {
+	return contentType == "image/gif" || contentType == "image/webp"
+}
 
-	if err := webp.Encode(dst, img, options); err != nil {
-		return fmt.Errorf("failed to encode webp: %w", err)
-	}
+// byteCounter counts bytes written to it.
+type byteCounter int64
 
-	return nil
+func (n *byteCounter) Write(p []byte) (int, error) {
+	*n += byteCounter(len(p))
+	return len(p), nil
 }
 
-func writeStream(fullPath string, reader io.Reader) error {
-	dst, err := os.Create(fullPath)
+// writeWebP re-encodes a photo as WebP. Only pixels are carried over, so
+// EXIF data such as GPS position is dropped; orientation is applied first.
+func (s *StorageService) writeWebP(key string, reader io.Reader, orientation int) (*StoredFile, error) {
+	img, _, err := image.Decode(reader)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
-	defer dst.Close()
+	img = applyOrientation(img, orientation)
 
-	if _, err := io.Copy(dst, reader); err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
+	dst, err := encodeWebP(s.cfg, img)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
-
-func shouldConvertToWebP(ext string, contentType string) bool {
-	contentType = normalizeContentType(contentType)
-	switch contentType {
-	case "image/jpeg", "image/jpg", "image/png":
-		return true
-	case "image/gif", "image/webp", "image/bmp", "image/tiff":
-		return false
+	checksum := sha256.Sum256(dst.Bytes())
+	stored := &StoredFile{
+		Path:     key,
+		Size:     int64(dst.Len()),
+		MimeType: "image/webp",
+		Width:    img.Bounds().Dx(),
+		Height:   img.Bounds().Dy(),
+		Checksum: hex.EncodeToString(checksum[:]),
 	}
-	if ext == ".gif" || ext == ".webp" {
-		return false
+	if err := s.backend.Put(key, dst, stored.Size, stored.MimeType); err != nil {
+		return nil, err
 	}
-	return ConvertibleToWebP[ext]
+	stored.Variants = s.writeVariants(key, img)
+	return stored, nil
 }
 
 func normalizeContentType(contentType string) string {
@@ -364,13 +417,3 @@ func extensionFromContentType(contentType string) string {
 		return ""
 	}
 }
-
-func isAllowedType(ext string, contentType string) bool {
-	if ext != "" && AllowedExtensions[ext] {
-		return true
-	}
-	if contentType != "" && AllowedMimeTypes[contentType] {
-		return true
-	}
-	return false
-}
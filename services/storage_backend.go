@@ -0,0 +1,108 @@
+package services
+
+import (
+	"eman-backend/config"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored file.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage is where uploaded files live. Keys are slash-separated paths
+// relative to the upload root, e.g. "2025/01/20250101_ab12cd34.webp".
+type Storage interface {
+	// Put stores r under key; size may be -1 when unknown.
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes key; a missing key is not an error.
+	Delete(key string) error
+	Stat(key string) (*ObjectInfo, error)
+	// URL is the address browsers should fetch key from.
+	URL(key string) string
+	// Walk calls fn for every stored key except reserved ones.
+	Walk(fn func(key string) error) error
+}
+
+// CleanKey turns a key taken from a URL into the key it names once
+// percent-decoding and "." and ".." segments are resolved.
+func CleanKey(raw string) string {
+	for {
+		decoded, err := url.PathUnescape(raw)
+		if err != nil || decoded == raw {
+			break
+		}
+		raw = decoded
+	}
+	return strings.TrimPrefix(path.Clean("/"+raw), "/")
+}
+
+// IsReservedKey reports whether key lies under a top-level directory starting
+// with "_", such as _quarantine/ and _resumable/. Those hold files that must
+// never be served or copied along with the public uploads.
+func IsReservedKey(key string) bool {
+	return strings.HasPrefix(CleanKey(key), "_")
+}
+
+// NewStorageBackend builds the backend with the given name: local or s3.
+func NewStorageBackend(cfg *config.Config, name string) (Storage, error) {
+	switch name {
+	case "", "local":
+		return NewLocalStorage(cfg.UploadDir), nil
+	case "s3":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", name)
+	}
+}
+
+// CopyStorage copies every public file from src to dst; reserved keys are
+// left behind. Files already in dst are skipped unless overwrite is set.
+func CopyStorage(src, dst Storage, overwrite bool) (copied, skipped int, err error) {
+	err = src.Walk(func(key string) error {
+		if IsReservedKey(key) {
+			return nil
+		}
+		if !overwrite {
+			if _, err := dst.Stat(key); err == nil {
+				skipped++
+				return nil
+			} else if !errors.Is(err, ErrObjectNotFound) {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+
+		info, err := src.Stat(key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		reader, err := src.Get(key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		defer reader.Close()
+
+		if err := dst.Put(key, reader, info.Size, info.ContentType); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		copied++
+		if copied%100 == 0 {
+			log.Printf("[Storage] copied %d files", copied)
+		}
+		return nil
+	})
+	return copied, skipped, err
+}
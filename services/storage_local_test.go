@@ -0,0 +1,72 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func putFile(t *testing.T, storage Storage, key string) {
+	t.Helper()
+	if err := storage.Put(key, strings.NewReader(key), int64(len(key)), "image/webp"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsReservedKey(t *testing.T) {
+	cases := map[string]bool{
+		"_quarantine/2025/01/a.webp":          true,
+		"_resumable/abc/00000000000000000000": true,
+		"/_quarantine/a.webp":                 true,
+		"./_quarantine/a.webp":                true,
+		"2025/../_quarantine/a.webp":          true,
+		"%5Fquarantine/a.webp":                true,
+		"%255Fquarantine/a.webp":              true,
+		"%2F_resumable/id/0":                  true,
+		"2025/01/a.webp":                      false,
+		"2025/_drafts/a.webp":                 false,
+		"gallery/a_b.webp":                    false,
+	}
+	for key, want := range cases {
+		if got := IsReservedKey(key); got != want {
+			t.Errorf("IsReservedKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLocalStorageWalkSkipsReservedKeys(t *testing.T) {
+	storage := NewLocalStorage(t.TempDir())
+	for _, key := range []string{"2025/01/a.webp", "b.webp", "_quarantine/2025/01/c.webp", "_resumable/id/00000000000000000000"} {
+		putFile(t, storage, key)
+	}
+
+	var keys []string
+	if err := storage.Walk(func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "2025/01/a.webp,b.webp" {
+		t.Fatalf("walked %v", keys)
+	}
+}
+
+func TestCopyStorageLeavesReservedKeysBehind(t *testing.T) {
+	src := NewLocalStorage(t.TempDir())
+	dst := NewLocalStorage(t.TempDir())
+	putFile(t, src, "2025/01/a.webp")
+	putFile(t, src, "_quarantine/2025/01/c.webp")
+
+	copied, skipped, err := CopyStorage(src, dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 1 || skipped != 0 {
+		t.Fatalf("copied %d, skipped %d", copied, skipped)
+	}
+	if _, err := dst.Stat("_quarantine/2025/01/c.webp"); err != ErrObjectNotFound {
+		t.Fatalf("quarantined file copied: %v", err)
+	}
+}
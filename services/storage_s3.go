@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"eman-backend/config"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Parts for uploads of unknown length; the client buffers one part in memory.
+const s3StreamPartSize = 16 * 1024 * 1024
+
+// S3Storage keeps files in an S3-compatible bucket (AWS S3, MinIO, R2, ...).
+// Objects are served straight from the bucket or from S3_PUBLIC_URL, so the
+// bucket (or the CDN in front of it) must allow public reads.
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	prefix    string
+	publicURL string
+	pathStyle bool
+}
+
+func NewS3Storage(cfg *config.Config) (*S3Storage, error) {
+	lookup := minio.BucketLookupAuto
+	if cfg.S3ForcePathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure:       cfg.S3UseSSL,
+		Region:       cfg.S3Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	s := &S3Storage{
+		client:    client,
+		bucket:    cfg.S3Bucket,
+		prefix:    strings.Trim(cfg.S3Prefix, "/"),
+		publicURL: strings.TrimRight(cfg.S3PublicURL, "/"),
+		pathStyle: cfg.S3ForcePathStyle,
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", s.bucket, err)
+	}
+	if !exists {
+		// Convenient with a fresh MinIO; on AWS the bucket is normally provisioned
+		if err := client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, fmt.Errorf("bucket %s does not exist and could not be created: %w", s.bucket, err)
+		}
+		log.Printf("[Storage] created bucket %s", s.bucket)
+	}
+
+	return s, nil
+}
+
+func (s *S3Storage) objectName(key string) string {
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	opts := minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	}
+	if ServeAsAttachment(key) {
+		opts.ContentDisposition = "attachment"
+	}
+	if size < 0 {
+		opts.PartSize = s3StreamPartSize
+	}
+	if _, err := s.client.PutObject(context.Background(), s.bucket, s.objectName(key), r, size, opts); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	// GetObject is lazy; Stat surfaces a missing key before the caller reads
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, s.mapError(err)
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, s.objectName(key), minio.RemoveObjectOptions{})
+}
+
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, s.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return &ObjectInfo{
+		Key:         key,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModTime:     info.LastModified,
+	}, nil
+}
+
+func (s *S3Storage) URL(key string) string {
+	name := (&url.URL{Path: s.objectName(key)}).EscapedPath()
+	if s.publicURL != "" {
+		return s.publicURL + "/" + name
+	}
+	endpoint := s.client.EndpointURL()
+	if s.pathStyle {
+		return endpoint.Scheme + "://" + endpoint.Host + "/" + s.bucket + "/" + name
+	}
+	return endpoint.Scheme + "://" + s.bucket + "." + endpoint.Host + "/" + name
+}
+
+func (s *S3Storage) Walk(fn func(key string) error) error {
+	opts := minio.ListObjectsOptions{Recursive: true}
+	if s.prefix != "" {
+		opts.Prefix = s.prefix + "/"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // stops the listing if fn fails
+
+	for object := range s.client.ListObjects(ctx, s.bucket, opts) {
+		if object.Err != nil {
+			return object.Err
+		}
+		key := strings.TrimPrefix(object.Key, opts.Prefix)
+		if strings.HasSuffix(key, "/") || IsReservedKey(key) {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Storage) mapError(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return ErrObjectNotFound
+	}
+	return err
+}
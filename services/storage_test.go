@@ -0,0 +1,55 @@
+package services
+
+import (
+	"bytes"
+	"eman-backend/config"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+func newTestStorageService(t *testing.T) *StorageService {
+	t.Helper()
+	return NewStorageService(&config.Config{}, NewLocalStorage(t.TempDir()), nil)
+}
+
+func TestStoreTIFFIsConvertedToWebP(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	for x := 0; x < 30; x++ {
+		img.Set(x, 0, color.RGBA{255, 0, 0, 255})
+	}
+	buf := &bytes.Buffer{}
+	if err := tiff.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// TIFF tags such as GPS only survive if the file is stored as uploaded
+	stored, err := newTestStorageService(t).saveFromReader(buf, int64(buf.Len()), "scan.tiff", "image/tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(stored.Path, ".webp") || stored.MimeType != "image/webp" {
+		t.Fatalf("stored as %s (%s)", stored.Path, stored.MimeType)
+	}
+	if stored.Width != 30 || stored.Height != 10 {
+		t.Fatalf("stored %dx%d", stored.Width, stored.Height)
+	}
+}
+
+func TestStoreJPEGAppliesOrientationAfterLargeSegments(t *testing.T) {
+	padding := bytes.Repeat([]byte{'x'}, 60000)
+	exif := append([]byte("Exif\x00\x00"), exifTIFF(binary.LittleEndian, 6)...)
+	data := testJPEG(t, 40, 20, jpegSegment(0xE2, padding), jpegSegment(0xE2, padding), jpegSegment(0xE1, exif))
+
+	stored, err := newTestStorageService(t).saveFromReader(bytes.NewReader(data), int64(len(data)), "photo.jpg", "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Width != 20 || stored.Height != 40 {
+		t.Fatalf("stored %dx%d, want the 20x40 upright photo", stored.Width, stored.Height)
+	}
+}
@@ -42,13 +42,27 @@ func (s *TelegramService) Enabled() bool {
 	return s != nil && s.botToken != "" && s.chatID != ""
 }
 
+// BotEnabled reports whether a bot token is set, so direct messages can be sent.
+func (s *TelegramService) BotEnabled() bool {
+	return s != nil && s.botToken != ""
+}
+
 func (s *TelegramService) SendMessage(text string) error {
 	if !s.Enabled() {
 		return nil
 	}
+	return s.SendMessageTo(s.chatID, text)
+}
+
+// SendMessageTo sends text to a specific chat, e.g. an admin's private chat
+// with the bot, rather than the notifications chat.
+func (s *TelegramService) SendMessageTo(chatID, text string) error {
+	if !s.BotEnabled() {
+		return fmt.Errorf("telegram bot is not configured")
+	}
 
 	form := url.Values{}
-	form.Set("chat_id", s.chatID)
+	form.Set("chat_id", chatID)
 	form.Set("text", text)
 
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.botToken)
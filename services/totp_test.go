@@ -0,0 +1,200 @@
+package services
+
+import (
+	"eman-backend/config"
+	"eman-backend/models"
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B, SHA1 with the 20-byte ASCII key "12345678901234567890".
+// The RFC lists 8-digit codes; a 6-digit code is their last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(vector.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := vector.code[2:]; code != want {
+			t.Errorf("T=%d: got %s, want %s", vector.unix, code, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	step, ok := ValidateTOTP(rfc6238Secret, "050 471", now, 0)
+	if !ok || step != current {
+		t.Fatalf("current code: step %d, ok %v", step, ok)
+	}
+
+	// One step of drift is tolerated, two are not
+	previous, _ := TOTPCode(rfc6238Secret, now.Add(-totpPeriod*time.Second))
+	if _, ok := ValidateTOTP(rfc6238Secret, previous, now, 0); !ok {
+		t.Error("code of the previous step was refused")
+	}
+	stale, _ := TOTPCode(rfc6238Secret, now.Add(-2*totpPeriod*time.Second))
+	if _, ok := ValidateTOTP(rfc6238Secret, stale, now, 0); ok {
+		t.Error("code two steps old was accepted")
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now, 0); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+}
+
+func TestValidateTOTPReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(rfc6238Secret, now)
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+	if !ok {
+		t.Fatal("fresh code was refused")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now, step); ok {
+		t.Fatal("code was accepted again after its step was used")
+	}
+	// A later step must not reopen an earlier code either
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now.Add(totpPeriod*time.Second), step); ok {
+		t.Fatal("used code was accepted within the drift window")
+	}
+}
+
+func TestRecoveryCodeNormalization(t *testing.T) {
+	if hashRecoveryCode(" AB12C-3DE45 ") != hashRecoveryCode("ab12c3de45") {
+		t.Fatal("formatting changed the recovery code hash")
+	}
+	if hashRecoveryCode("ab12c-3de45") == hashRecoveryCode("ab12c-3de46") {
+		t.Fatal("different codes share a hash")
+	}
+}
+
+func TestTwoFactorVerifyConsumesStep(t *testing.T) {
+	db := useTestDB(t, &models.AdminUser{}, &models.AdminRecoveryCode{})
+	secret, _ := GenerateTOTPSecret()
+	admin := models.AdminUser{
+		Username:    fmt.Sprintf("totp-test-%d", time.Now().UnixNano()),
+		Role:        models.RoleOwner,
+		TOTPSecret:  secret,
+		TOTPEnabled: true,
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Delete(&admin) })
+
+	service := NewTwoFactorService(nil)
+	code, _ := TOTPCode(secret, time.Now())
+	if err := service.Verify(&admin, code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	// A second request holding a stale copy of the admin must still be refused
+	stale := admin
+	stale.TOTPLastStep = 0
+	if err := service.Verify(&stale, code); err != ErrTwoFactorInvalid {
+		t.Fatalf("replayed code: got %v", err)
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	db := useTestDB(t, &models.AdminUser{}, &models.AdminRecoveryCode{})
+	admin := models.AdminUser{Username: fmt.Sprintf("recovery-test-%d", time.Now().UnixNano()), Role: models.RoleOwner}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("admin_user_id = ?", admin.ID).Delete(&models.AdminRecoveryCode{})
+		db.Delete(&admin)
+	})
+
+	service := NewTwoFactorService(nil)
+	codes, err := service.RegenerateRecoveryCodes(admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount || service.RecoveryCodesLeft(admin.ID) != recoveryCodeCount {
+		t.Fatalf("got %d codes", len(codes))
+	}
+
+	if err := service.UseRecoveryCode(&admin, codes[0]); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := service.UseRecoveryCode(&admin, codes[0]); err != ErrTwoFactorInvalid {
+		t.Fatalf("second use: got %v", err)
+	}
+	if left := service.RecoveryCodesLeft(admin.ID); left != recoveryCodeCount-1 {
+		t.Fatalf("%d codes left", left)
+	}
+
+	// Regenerating invalidates every old code
+	if _, err := service.RegenerateRecoveryCodes(admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.UseRecoveryCode(&admin, codes[1]); err != ErrTwoFactorInvalid {
+		t.Fatalf("old code after regeneration: got %v", err)
+	}
+}
+
+func TestConfirmEnrollmentRefusesEnrolledAdmin(t *testing.T) {
+	db := useTestDB(t, &models.AdminUser{}, &models.AdminRecoveryCode{})
+	admin := models.AdminUser{Username: fmt.Sprintf("enroll-test-%d", time.Now().UnixNano()), Role: models.RoleOwner}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("admin_user_id = ?", admin.ID).Delete(&models.AdminRecoveryCode{})
+		db.Delete(&admin)
+	})
+	service := NewTwoFactorService(&config.Config{TOTPIssuer: "Test"})
+
+	// Someone holding only the password got a setup token before this
+	stale := admin
+
+	// The admin enrolls with their own secret
+	secret, _, err := service.BeginEnrollment(&admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.TOTPPendingSecret = secret
+	code, _ := TOTPCode(secret, time.Now())
+	if _, err := service.ConfirmEnrollment(&admin, code); err != nil {
+		t.Fatalf("admin enrollment: %v", err)
+	}
+
+	// The stale token can no longer swap in a secret of its own
+	attackerSecret, _, err := service.BeginEnrollment(&stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.TOTPPendingSecret = attackerSecret
+	code, _ = TOTPCode(attackerSecret, time.Now())
+	if _, err := service.ConfirmEnrollment(&stale, code); err != ErrTwoFactorNotPending {
+		t.Fatalf("stale enrollment: got %v", err)
+	}
+	var stored models.AdminUser
+	db.First(&stored, admin.ID)
+	if stored.TOTPSecret != secret {
+		t.Fatal("enrolled secret was replaced")
+	}
+}
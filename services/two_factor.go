@@ -0,0 +1,221 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"eman-backend/config"
+	"eman-backend/database"
+	"eman-backend/models"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTwoFactorNotPending = errors.New("two-factor setup has not been started")
+	ErrTwoFactorInvalid    = errors.New("invalid two-factor code")
+)
+
+const recoveryCodeCount = 10
+
+// TwoFactorService manages TOTP enrollment, verification and recovery codes.
+type TwoFactorService struct {
+	cfg *config.Config
+}
+
+func NewTwoFactorService(cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{cfg: cfg}
+}
+
+// Policy returns the current security policy, defaulting to an empty one.
+func (s *TwoFactorService) Policy() models.SecurityPolicy {
+	var policy models.SecurityPolicy
+	database.DB.Order("id ASC").First(&policy)
+	return policy
+}
+
+// Required reports whether every admin must use 2FA.
+func (s *TwoFactorService) Required() bool {
+	return s.Policy().Require2FA
+}
+
+// SetRequired updates the owner policy.
+func (s *TwoFactorService) SetRequired(required bool, updatedBy string) (models.SecurityPolicy, error) {
+	policy := s.Policy()
+	policy.Require2FA = required
+	policy.UpdatedBy = updatedBy
+	err := database.DB.Save(&policy).Error
+	return policy, err
+}
+
+// BeginEnrollment generates a pending secret and its provisioning URI. The
+// secret only becomes active once ConfirmEnrollment sees a valid code.
+func (s *TwoFactorService) BeginEnrollment(admin *models.AdminUser) (string, string, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := database.DB.Model(admin).Update("totp_pending_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+	return secret, TOTPProvisioningURI(s.cfg.TOTPIssuer, admin.Username, secret), nil
+}
+
+// ConfirmEnrollment activates the pending secret and returns fresh recovery codes.
+func (s *TwoFactorService) ConfirmEnrollment(admin *models.AdminUser, code string) ([]string, error) {
+	if admin.TOTPPendingSecret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+	step, ok := ValidateTOTP(admin.TOTPPendingSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrTwoFactorInvalid
+	}
+
+	now := time.Now()
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only an admin who has not enrolled meanwhile, and only with the
+		// secret this code was checked against; a stale setup token must not
+		// replace the secret of an enrolled admin.
+		result := tx.Model(&models.AdminUser{}).
+			Where("id = ? AND totp_enabled = ? AND totp_pending_secret = ?", admin.ID, false, admin.TOTPPendingSecret).
+			Updates(map[string]interface{}{
+				"totp_secret":         admin.TOTPPendingSecret,
+				"totp_pending_secret": "",
+				"totp_enabled":        true,
+				"totp_enabled_at":     &now,
+				"totp_last_step":      step,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTwoFactorNotPending
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, admin.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	admin.TOTPSecret = admin.TOTPPendingSecret
+	admin.TOTPPendingSecret = ""
+	admin.TOTPEnabled = true
+	admin.TOTPEnabledAt = &now
+	admin.TOTPLastStep = step
+	return codes, nil
+}
+
+// Verify checks a TOTP code for an enrolled admin and consumes its time step.
+func (s *TwoFactorService) Verify(admin *models.AdminUser, code string) error {
+	if !admin.TOTPEnabled {
+		return ErrTwoFactorInvalid
+	}
+	step, ok := ValidateTOTP(admin.TOTPSecret, code, time.Now(), admin.TOTPLastStep)
+	if !ok {
+		return ErrTwoFactorInvalid
+	}
+
+	// Conditional update so two concurrent requests cannot both use one code
+	result := database.DB.Model(&models.AdminUser{}).
+		Where("id = ? AND totp_last_step < ?", admin.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTwoFactorInvalid
+	}
+	admin.TOTPLastStep = step
+	return nil
+}
+
+// UseRecoveryCode consumes one of the admin's recovery codes.
+func (s *TwoFactorService) UseRecoveryCode(admin *models.AdminUser, code string) error {
+	hash := hashRecoveryCode(code)
+	result := database.DB.Model(&models.AdminRecoveryCode{}).
+		Where("admin_user_id = ? AND code_hash = ? AND used_at IS NULL", admin.ID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTwoFactorInvalid
+	}
+	return nil
+}
+
+// RecoveryCodesLeft counts the admin's unused recovery codes.
+func (s *TwoFactorService) RecoveryCodesLeft(adminID uint) int64 {
+	var count int64
+	database.DB.Model(&models.AdminRecoveryCode{}).
+		Where("admin_user_id = ? AND used_at IS NULL", adminID).
+		Count(&count)
+	return count
+}
+
+// RegenerateRecoveryCodes invalidates the old codes and issues new ones.
+func (s *TwoFactorService) RegenerateRecoveryCodes(adminID uint) ([]string, error) {
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, adminID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable turns 2FA off and drops the secret and recovery codes.
+func (s *TwoFactorService) Disable(adminID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AdminUser{}).Where("id = ?", adminID).Updates(map[string]interface{}{
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_enabled":        false,
+			"totp_enabled_at":     nil,
+			"totp_last_step":      0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("admin_user_id = ?", adminID).Delete(&models.AdminRecoveryCode{}).Error
+	})
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceRecoveryCodes issues recoveryCodeCount codes formatted "xxxxx-xxxxx".
+func replaceRecoveryCodes(tx *gorm.DB, adminID uint) ([]string, error) {
+	if err := tx.Where("admin_user_id = ?", adminID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.AdminRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(buf)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		rows = append(rows, models.AdminRecoveryCode{AdminUserID: adminID, CodeHash: hashRecoveryCode(code)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
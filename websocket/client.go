@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"eman-backend/models"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer
+	PongWait = 60 * time.Second
+
+	// Send pings to peer with this period (must be less than PongWait)
+	pingPeriod = (PongWait * 9) / 10
+
+	// Messages buffered per client before it counts as too slow
+	sendQueueSize = 256
+)
+
+// Client represents a WebSocket client, or an SSE stream when Conn is nil
+type Client struct {
+	Conn *websocket.Conn
+	Hub  *Hub
+
+	// Outgoing frames; writePump is the only goroutine writing to Conn.
+	sendMu sync.Mutex
+	send   chan []byte
+	closed bool
+	done   chan struct{} // closed once writePump has returned
+
+	mu            sync.RWMutex
+	username      string
+	role          string
+	authExpiresAt time.Time
+	topics        map[string]bool
+}
+
+func NewClient(conn *websocket.Conn, hub *Hub) *Client {
+	return &Client{
+		Conn:   conn,
+		Hub:    hub,
+		send:   make(chan []byte, sendQueueSize),
+		done:   make(chan struct{}),
+		topics: make(map[string]bool),
+	}
+}
+
+// NewStreamClient creates a client without a socket; its owner reads the
+// queued frames from Outgoing, as the Server-Sent Events stream does.
+func NewStreamClient(hub *Hub) *Client {
+	return NewClient(nil, hub)
+}
+
+// Outgoing returns the client's frame queue. It is closed when the hub drops
+// the client.
+func (c *Client) Outgoing() <-chan []byte {
+	return c.send
+}
+
+// Authenticate attaches an admin identity until the access token expires.
+// Sending a fresh token extends it.
+func (c *Client) Authenticate(username, role string, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.username = username
+	c.role = role
+	c.authExpiresAt = expiresAt
+}
+
+// Username returns the authenticated admin, or "" for anonymous clients.
+func (c *Client) Username() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username
+}
+
+// CanAccess reports whether the client may receive messages on topic.
+func (c *Client) CanAccess(topic string) bool {
+	perm, ok := topicPermissions[topic]
+	if !ok {
+		return false
+	}
+	if perm == "" {
+		return true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.username == "" || !time.Now().Before(c.authExpiresAt) {
+		return false
+	}
+	return models.RoleHasPermission(c.role, perm)
+}
+
+// Subscribe adds the topics the client may access and returns the rest as denied.
+func (c *Client) Subscribe(topics []string) (subscribed, denied []string) {
+	subscribed, denied = []string{}, []string{}
+	for _, topic := range topics {
+		if !c.CanAccess(topic) {
+			denied = append(denied, topic)
+			continue
+		}
+		subscribed = append(subscribed, topic)
+	}
+
+	c.mu.Lock()
+	for _, topic := range subscribed {
+		c.topics[topic] = true
+	}
+	c.mu.Unlock()
+	return subscribed, denied
+}
+
+// Unsubscribe removes topics from the client.
+func (c *Client) Unsubscribe(topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.topics, topic)
+	}
+}
+
+// Wants reports whether a message on topic should be delivered to the client.
+// Access is re-checked so admin topics stop once the token has expired.
+func (c *Client) Wants(topic string) bool {
+	c.mu.RLock()
+	subscribed := c.topics[topic]
+	c.mu.RUnlock()
+	return subscribed && c.CanAccess(topic)
+}
+
+// enqueue queues a frame without blocking. It returns false when the queue
+// is full; a closed client silently discards the frame.
+func (c *Client) enqueue(data []byte) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.closed {
+		return true
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// freeSlots is how many more frames fit in the send queue right now.
+func (c *Client) freeSlots() int {
+	return cap(c.send) - len(c.send)
+}
+
+// close stops the writer, which then closes the connection.
+func (c *Client) close() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// Send queues a message for this client only. A client whose queue is full
+// is disconnected.
+func (c *Client) Send(msgType string, payload interface{}) {
+	data, err := json.Marshal(Message{Type: msgType, Payload: payload})
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	c.SendRaw(data)
+}
+
+// SendRaw queues a text frame for this client only.
+func (c *Client) SendRaw(data []byte) {
+	if !c.enqueue(data) {
+		c.close()
+	}
+}
+
+// Wait blocks until the writer has stopped. The connection is recycled once
+// the handler returns, so the handler must not return before that.
+func (c *Client) Wait() {
+	<-c.done
+}
+
+// writePump drains the send queue and keeps the connection alive with pings.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+		close(c.done)
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -3,30 +3,80 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"strconv"
 	"sync"
+	"time"
+)
+
+const (
+	// Pending publishes; Publish drops messages rather than block when it is full.
+	broadcastQueueSize = 1024
+
+	// Published messages kept for clients resuming after a reconnect
+	replayBufferSize = 1000
 
-	"github.com/gofiber/contrib/websocket"
+	// Backplane channel carrying hub messages between instances
+	backplaneChannel = "eman_hub"
 )
 
-// Message represents a WebSocket message
+// Backplane fans published messages out to every backend instance. A
+// shared backplane numbers the messages (seq > 0), and the hub then uses
+// those numbers so a client can resume on any instance.
+type Backplane interface {
+	Publish(channel string, payload []byte) error
+	Subscribe(channel string, handler func(seq uint64, payload []byte))
+}
+
+// positioner is a backplane that numbers messages; Position returns the
+// shared epoch and the number delivery starts after.
+type positioner interface {
+	Position(channel string) (epoch string, seq uint64, err error)
+}
+
+// Message represents a WebSocket message. Published messages carry a
+// sequence number shared by all topics, so a client sees gaps for topics it
+// does not follow; replies to a single client have none.
 type Message struct {
+	Seq     uint64      `json:"seq,omitempty"`
+	Topic   string      `json:"topic,omitempty"`
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
 }
 
-// Client represents a WebSocket client
-type Client struct {
-	Conn *websocket.Conn
-	Hub  *Hub
+// sentMessage is a published message together with its encoded form.
+type sentMessage struct {
+	Message
+	data []byte
+}
+
+type resumeRequest struct {
+	client  *Client
+	epoch   string
+	lastSeq uint64
 }
 
-// Hub maintains active clients and broadcasts messages
+// Hub maintains active clients and broadcasts messages. Only run touches the
+// client set; each client has its own send queue and writer goroutine, so a
+// slow socket never holds up the others or the publisher.
 type Hub struct {
 	clients    map[*Client]bool
 	broadcast  chan Message
 	register   chan *Client
 	unregister chan *Client
+	resume     chan resumeRequest
 	mutex      sync.RWMutex
+
+	backplane Backplane
+	outbound  chan Message  // publishes waiting to go out over the backplane
+	lost      chan struct{} // a publish that failed on a shared backplane
+
+	// Sequence numbers restart with the process unless the backplane shares
+	// them; the epoch tells clients that their last_seq belongs to another
+	// numbering.
+	epoch   string
+	seq     uint64
+	history []sentMessage // ring buffer of the last replayBufferSize messages
+	next    int           // history slot the next message goes to
 }
 
 var (
@@ -37,17 +87,27 @@ var (
 // GetHub returns the singleton Hub instance
 func GetHub() *Hub {
 	once.Do(func() {
-		instance = &Hub{
-			clients:    make(map[*Client]bool),
-			broadcast:  make(chan Message),
-			register:   make(chan *Client),
-			unregister: make(chan *Client),
-		}
-		go instance.run()
+		instance = NewHub()
 	})
 	return instance
 }
 
+// NewHub creates a hub and starts its dispatch loop.
+func NewHub() *Hub {
+	hub := &Hub{
+		clients:    make(map[*Client]bool),
+		broadcast:  make(chan Message, broadcastQueueSize),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		resume:     make(chan resumeRequest),
+		lost:       make(chan struct{}, 1),
+		epoch:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		history:    make([]sentMessage, 0, replayBufferSize),
+	}
+	go hub.run()
+	return hub
+}
+
 // run handles client registration and message broadcasting
 func (h *Hub) run() {
 	for {
@@ -55,39 +115,173 @@ func (h *Hub) run() {
 		case client := <-h.register:
 			h.mutex.Lock()
 			h.clients[client] = true
+			total := len(h.clients)
 			h.mutex.Unlock()
-			log.Printf("WebSocket client connected. Total clients: %d", len(h.clients))
+			if client.Conn != nil {
+				go client.writePump()
+			}
+			client.Send("welcome", map[string]interface{}{
+				"epoch": h.epoch,
+				"seq":   h.seq,
+			})
+			log.Printf("WebSocket client connected. Total clients: %d", total)
 
 		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				client.Conn.Close()
-			}
-			h.mutex.Unlock()
-			log.Printf("WebSocket client disconnected. Total clients: %d", len(h.clients))
+			h.remove(client)
+
+		case req := <-h.resume:
+			h.replay(req)
+
+		case <-h.lost:
+			h.resync(h.seq)
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
+			// Numbered by a shared backplane: skip repeats, and when messages
+			// went missing clients cannot trust their state any more
+			if message.Seq > 0 {
+				if message.Seq <= h.seq {
+					continue
+				}
+				if message.Seq > h.seq+1 {
+					log.Printf("WebSocket hub missed messages %d-%d, asking clients to resync", h.seq+1, message.Seq-1)
+					h.resync(message.Seq - 1)
+				}
+				h.seq = message.Seq - 1
+			}
+			h.seq++
+			message.Seq = h.seq
 			data, err := json.Marshal(message)
 			if err != nil {
 				log.Printf("Error marshaling message: %v", err)
-				h.mutex.RUnlock()
 				continue
 			}
+			h.remember(sentMessage{Message: message, data: data})
+
+			h.mutex.RLock()
+			var slow []*Client
 			for client := range h.clients {
-				if err := client.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
-					log.Printf("Error sending message: %v", err)
-					client.Conn.Close()
-					delete(h.clients, client)
+				if !client.Wants(message.Topic) {
+					continue
+				}
+				if !client.enqueue(data) {
+					slow = append(slow, client)
 				}
 			}
 			h.mutex.RUnlock()
+
+			// A full queue means the client cannot keep up; disconnecting lets it
+			// reconnect and catch up instead of silently missing messages.
+			for _, client := range slow {
+				log.Printf("WebSocket client %q too slow, disconnecting", client.Username())
+				h.remove(client)
+			}
+		}
+	}
+}
+
+// resync empties the replay buffer and tells every client to reload, after
+// messages were lost. seq is the number the next message follows.
+func (h *Hub) resync(seq uint64) {
+	h.seq = seq
+	h.history = h.history[:0]
+	h.next = 0
+
+	data, err := json.Marshal(Message{Type: "resync_required", Payload: map[string]interface{}{
+		"epoch": h.epoch,
+		"seq":   h.seq,
+	}})
+	if err != nil {
+		return
+	}
+	h.mutex.RLock()
+	var slow []*Client
+	for client := range h.clients {
+		if !client.enqueue(data) {
+			slow = append(slow, client)
+		}
+	}
+	h.mutex.RUnlock()
+	for _, client := range slow {
+		h.remove(client)
+	}
+}
+
+func (h *Hub) remember(message sentMessage) {
+	if len(h.history) < replayBufferSize {
+		h.history = append(h.history, message)
+	} else {
+		h.history[h.next] = message
+	}
+	h.next = (h.next + 1) % replayBufferSize
+}
+
+// oldestSeq is the first sequence number still in the replay buffer.
+func (h *Hub) oldestSeq() uint64 {
+	if len(h.history) < replayBufferSize {
+		if len(h.history) == 0 {
+			return h.seq + 1
 		}
+		return h.history[0].Seq
 	}
+	return h.history[h.next].Seq
 }
 
-// Register adds a new client to the hub
+// replay sends a reconnecting client what it missed on its topics, or tells
+// it to reload everything when the gap is no longer in the buffer. It runs on
+// the hub goroutine, so live messages queue up behind the replayed ones.
+func (h *Hub) replay(req resumeRequest) {
+	client := req.client
+	if req.epoch != h.epoch || req.lastSeq > h.seq || req.lastSeq+1 < h.oldestSeq() {
+		client.Send("resync_required", map[string]interface{}{
+			"epoch": h.epoch,
+			"seq":   h.seq,
+		})
+		return
+	}
+
+	var missed [][]byte
+	for i := 0; i < len(h.history); i++ {
+		message := h.history[(h.next-len(h.history)+i+replayBufferSize)%replayBufferSize]
+		if message.Seq <= req.lastSeq || !client.Wants(message.Topic) {
+			continue
+		}
+		missed = append(missed, message.data)
+	}
+
+	// An SSE stream resumes before its writer starts draining, so a gap the
+	// send queue cannot hold (with room for "resumed") is a resync too
+	if len(missed) >= client.freeSlots() {
+		client.Send("resync_required", map[string]interface{}{
+			"epoch": h.epoch,
+			"seq":   h.seq,
+		})
+		return
+	}
+
+	for _, data := range missed {
+		client.SendRaw(data)
+	}
+	client.Send("resumed", map[string]interface{}{
+		"from":     req.lastSeq,
+		"seq":      h.seq,
+		"replayed": len(missed),
+	})
+}
+
+func (h *Hub) remove(client *Client) {
+	h.mutex.Lock()
+	_, ok := h.clients[client]
+	delete(h.clients, client)
+	total := len(h.clients)
+	h.mutex.Unlock()
+
+	if ok {
+		client.close()
+		log.Printf("WebSocket client disconnected. Total clients: %d", total)
+	}
+}
+
+// Register adds a new client to the hub and starts its writer
 func (h *Hub) Register(client *Client) {
 	h.register <- client
 }
@@ -97,11 +291,95 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
-// Broadcast sends a message to all connected clients
-func (h *Hub) Broadcast(msgType string, payload interface{}) {
-	h.broadcast <- Message{
-		Type:    msgType,
-		Payload: payload,
+// Resume replays the messages a registered client missed since lastSeq.
+func (h *Hub) Resume(client *Client, epoch string, lastSeq uint64) {
+	h.resume <- resumeRequest{client: client, epoch: epoch, lastSeq: lastSeq}
+}
+
+// Epoch identifies this run of the hub; sequence numbers are only
+// comparable within one epoch.
+func (h *Hub) Epoch() string {
+	return h.epoch
+}
+
+// SetBackplane routes publishes through b so that clients on every instance
+// receive them. Call it once at startup, before any client connects.
+func (h *Hub) SetBackplane(b Backplane) {
+	h.backplane = b
+	h.outbound = make(chan Message, broadcastQueueSize)
+	if p, ok := b.(positioner); ok {
+		epoch, seq, err := p.Position(backplaneChannel)
+		if err != nil {
+			log.Printf("WebSocket backplane position unknown, numbering locally: %v", err)
+		} else {
+			h.epoch, h.seq = "bp-"+epoch, seq
+		}
+	}
+	b.Subscribe(backplaneChannel, h.receive)
+	go h.forward()
+}
+
+// forward sends publishes over the backplane. Without a shared numbering a
+// message it cannot send is still delivered to this instance's clients;
+// with one it is lost everywhere, so local clients are told to resync.
+func (h *Hub) forward() {
+	_, shared := h.backplane.(positioner)
+	for message := range h.outbound {
+		data, err := json.Marshal(message)
+		if err == nil {
+			err = h.backplane.Publish(backplaneChannel, data)
+		}
+		if err == nil {
+			continue
+		}
+		if !shared {
+			log.Printf("WebSocket backplane publish of %s failed, delivering locally: %v", message.Type, err)
+			h.dispatch(message)
+			continue
+		}
+		log.Printf("WebSocket backplane publish of %s failed, dropping it: %v", message.Type, err)
+		select {
+		case h.lost <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// receive takes a message from the backplane, published on any instance.
+func (h *Hub) receive(seq uint64, payload []byte) {
+	var message struct {
+		Topic   string          `json:"topic"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		log.Printf("WebSocket backplane message dropped: %v", err)
+		return
+	}
+	h.dispatch(Message{Seq: seq, Topic: message.Topic, Type: message.Type, Payload: message.Payload})
+}
+
+func (h *Hub) dispatch(message Message) {
+	select {
+	case h.broadcast <- message:
+	default:
+		log.Printf("WebSocket hub queue full, dropping %s message on %s", message.Type, message.Topic)
+	}
+}
+
+// Publish sends a message to the clients subscribed to topic that may access
+// it, on every instance. It never blocks; when the hub is backed up the
+// message is dropped.
+func (h *Hub) Publish(topic, msgType string, payload interface{}) {
+	message := Message{Topic: topic, Type: msgType, Payload: payload}
+	if h.backplane == nil {
+		h.dispatch(message)
+		return
+	}
+	select {
+	case h.outbound <- message:
+	default:
+		log.Printf("WebSocket backplane queue full, dropping %s message on %s", msgType, topic)
 	}
 }
 
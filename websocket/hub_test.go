@@ -0,0 +1,315 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Every connect and disconnect is logged
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// drain reads a stream client's frames until the hub closes it, counting the
+// published ones.
+func drain(client *Client, published *atomic.Int64) {
+	for data := range client.Outgoing() {
+		var message Message
+		if json.Unmarshal(data, &message) == nil && message.Type == "estate_updated" {
+			published.Add(1)
+		}
+	}
+}
+
+// waitFor polls cond until it holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHubSlowClientDoesNotHoldUpOthers(t *testing.T) {
+	const (
+		clients = 300
+		rounds  = 4
+		burst   = sendQueueSize / 2 // healthy clients keep up with this
+	)
+	hub := NewHub()
+
+	counts := make([]atomic.Int64, clients)
+	var readers sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		client := NewStreamClient(hub)
+		client.Subscribe([]string{TopicPublicEstates})
+		hub.Register(client)
+		readers.Add(1)
+		go func(i int) {
+			defer readers.Done()
+			drain(client, &counts[i])
+		}(i)
+	}
+
+	// Never read from: its queue fills up and the hub must drop it
+	stalled := NewStreamClient(hub)
+	stalled.Subscribe([]string{TopicPublicEstates})
+	hub.Register(stalled)
+
+	published := 0
+	for round := 0; round < rounds; round++ {
+		// Publish from several goroutines at once
+		var publishers sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			publishers.Add(1)
+			go func(p int) {
+				defer publishers.Done()
+				for i := p; i < burst; i += 4 {
+					hub.Publish(TopicPublicEstates, "estate_updated", map[string]int{"id": i})
+				}
+			}(p)
+		}
+		publishers.Wait()
+		published += burst
+
+		waitFor(t, "healthy clients to receive every message", func() bool {
+			for i := range counts {
+				if counts[i].Load() != int64(published) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	if n := hub.ClientCount(); n != clients {
+		t.Fatalf("%d clients connected, want %d", n, clients)
+	}
+
+	// The stalled client was disconnected: its queue holds what fitted and
+	// is then closed
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-stalled.Outgoing():
+			if open {
+				received++
+			}
+		case <-timeout:
+			t.Fatal("stalled client was not disconnected")
+		}
+	}
+	if received > sendQueueSize {
+		t.Fatalf("stalled client received %d frames, queue holds %d", received, sendQueueSize)
+	}
+
+	// Unregister through the hub; drain returns once each queue closes
+	hub.mutex.RLock()
+	remaining := make([]*Client, 0, len(hub.clients))
+	for client := range hub.clients {
+		remaining = append(remaining, client)
+	}
+	hub.mutex.RUnlock()
+	for _, client := range remaining {
+		hub.Unregister(client)
+	}
+	readers.Wait()
+}
+
+func TestHubConcurrentRegistration(t *testing.T) {
+	hub := NewHub()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := NewStreamClient(hub)
+			client.Subscribe([]string{TopicPublicSettings})
+			hub.Register(client)
+			hub.Publish(TopicPublicSettings, "settings_updated", nil)
+			hub.Resume(client, hub.Epoch(), 0)
+			hub.Unregister(client)
+			for range client.Outgoing() {
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A client whose queue overflowed during replay is closed before the hub
+	// gets to its unregistration
+	waitFor(t, "every client to be unregistered", func() bool { return hub.ClientCount() == 0 })
+}
+
+// nextFrame returns the type of the next frame queued for client.
+func nextFrame(t *testing.T, client *Client) Message {
+	t.Helper()
+	select {
+	case data, ok := <-client.Outgoing():
+		if !ok {
+			t.Fatal("client was disconnected")
+		}
+		var message Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame queued")
+	}
+	return Message{}
+}
+
+func TestHubReplayBeyondQueueRequiresResync(t *testing.T) {
+	hub := NewHub()
+
+	// A live subscriber shows when every message has been sequenced
+	var seen atomic.Int64
+	watcher := NewStreamClient(hub)
+	watcher.Subscribe([]string{TopicPublicEstates})
+	hub.Register(watcher)
+	go drain(watcher, &seen)
+
+	const published = sendQueueSize + 50
+	for i := 0; i < published; i++ {
+		hub.Publish(TopicPublicEstates, "estate_updated", map[string]int{"id": i})
+		if i%100 == 99 {
+			waitFor(t, "messages to be sequenced", func() bool { return seen.Load() == int64(i+1) })
+		}
+	}
+	waitFor(t, "messages to be sequenced", func() bool { return seen.Load() == published })
+
+	// Nothing drains these streams yet, as with SSE before the writer starts
+	resume := func(lastSeq uint64) *Client {
+		client := NewStreamClient(hub)
+		client.Subscribe([]string{TopicPublicEstates})
+		hub.Register(client)
+		if message := nextFrame(t, client); message.Type != "welcome" {
+			t.Fatalf("got %s, want welcome", message.Type)
+		}
+		hub.Resume(client, hub.Epoch(), lastSeq)
+		return client
+	}
+
+	// The whole gap fits in the buffer but not in the send queue
+	far := resume(0)
+	if message := nextFrame(t, far); message.Type != "resync_required" {
+		t.Fatalf("got %s, want resync_required", message.Type)
+	}
+
+	// A small gap is replayed, followed by "resumed"
+	near := resume(published - 10)
+	for i := 0; i < 10; i++ {
+		if message := nextFrame(t, near); message.Type != "estate_updated" || message.Seq != uint64(published-10+i+1) {
+			t.Fatalf("replayed frame %d: %s seq %d", i, message.Type, message.Seq)
+		}
+	}
+	if message := nextFrame(t, near); message.Type != "resumed" {
+		t.Fatalf("got %s, want resumed", message.Type)
+	}
+
+	for _, client := range []*Client{watcher, far, near} {
+		hub.Unregister(client)
+	}
+}
+
+// sequencedBackplane numbers messages like a shared backplane. With drop set
+// a message is numbered but never delivered; with fail set Publish errors.
+type sequencedBackplane struct {
+	mu      sync.Mutex
+	seq     uint64
+	handler func(seq uint64, payload []byte)
+	drop    bool
+	fail    bool
+}
+
+func (b *sequencedBackplane) Position(string) (string, uint64, error) {
+	return "shared", 41, nil
+}
+
+func (b *sequencedBackplane) Subscribe(_ string, handler func(seq uint64, payload []byte)) {
+	b.mu.Lock()
+	b.seq = 41
+	b.handler = handler
+	b.mu.Unlock()
+}
+
+func (b *sequencedBackplane) Publish(_ string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail {
+		return errors.New("backplane unavailable")
+	}
+	b.seq++
+	if !b.drop {
+		b.handler(b.seq, payload)
+	}
+	return nil
+}
+
+func (b *sequencedBackplane) last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+func (b *sequencedBackplane) set(drop, fail bool) {
+	b.mu.Lock()
+	b.drop, b.fail = drop, fail
+	b.mu.Unlock()
+}
+
+func TestHubFollowsBackplaneSequence(t *testing.T) {
+	hub := NewHub()
+	backplane := &sequencedBackplane{}
+	hub.SetBackplane(backplane)
+	if hub.Epoch() != "bp-shared" {
+		t.Fatalf("epoch %q, want the backplane's", hub.Epoch())
+	}
+
+	client := NewStreamClient(hub)
+	client.Subscribe([]string{TopicPublicEstates})
+	hub.Register(client)
+	if message := nextFrame(t, client); message.Type != "welcome" {
+		t.Fatalf("got %s, want welcome", message.Type)
+	}
+
+	hub.Publish(TopicPublicEstates, "estate_updated", nil)
+	if message := nextFrame(t, client); message.Seq != 42 {
+		t.Fatalf("got seq %d, want the backplane's 42", message.Seq)
+	}
+
+	// A message lost between instances leaves a gap
+	backplane.set(true, false)
+	hub.Publish(TopicPublicEstates, "estate_updated", nil)
+	waitFor(t, "the lost message to be numbered", func() bool { return backplane.last() == 43 })
+	backplane.set(false, false)
+	hub.Publish(TopicPublicEstates, "estate_updated", nil)
+	if message := nextFrame(t, client); message.Type != "resync_required" {
+		t.Fatalf("got %s, want resync_required after a gap", message.Type)
+	}
+	if message := nextFrame(t, client); message.Seq != 44 {
+		t.Fatalf("got seq %d, want 44", message.Seq)
+	}
+
+	// A publish that never reached the backplane is not delivered locally
+	backplane.set(false, true)
+	hub.Publish(TopicPublicEstates, "estate_updated", nil)
+	if message := nextFrame(t, client); message.Type != "resync_required" {
+		t.Fatalf("got %s, want resync_required after a failed publish", message.Type)
+	}
+
+	hub.Unregister(client)
+}
@@ -0,0 +1,25 @@
+package websocket
+
+import "eman-backend/models"
+
+// Topics clients can subscribe to. admin.* topics need a signed-in admin
+// with the listed permission; public.* topics are open to everyone.
+const (
+	TopicAdminSubmissions = "admin.submissions"
+	TopicPublicEstates    = "public.estates"
+	TopicPublicSettings   = "public.settings"
+	TopicPublicContent    = "public.content"
+)
+
+var topicPermissions = map[string]string{
+	TopicAdminSubmissions: models.PermSubmissionsRead,
+	TopicPublicEstates:    "",
+	TopicPublicSettings:   "",
+	TopicPublicContent:    "",
+}
+
+// ValidTopic reports whether topic is known.
+func ValidTopic(topic string) bool {
+	_, ok := topicPermissions[topic]
+	return ok
+}